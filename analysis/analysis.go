@@ -0,0 +1,143 @@
+// Package analysis provides an analyzer reporting grr.Errorf calls, with suggested fixes converting them to generated errors.
+// It runs as a standalone checker, see cmd/grrvet, and with go vet -vettool=$(which grrvet)
+package analysis
+
+import (
+	"go/token"
+	"path/filepath"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/gen"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+const doc = `report grr.Errorf calls that aren't generated errors yet
+
+The grr analyzer finds the grr.Errorf calls that grr gen would convert to generated errors,
+along with those it can't convert because of an invalid name, message or format.
+Every call that can be converted comes with a suggested fix converting only that call.
+A new error is declared at the end of the generated file of the package, where the next grr gen sorts it in.
+Calls of new errors have no fix before grr gen has created that file, since a fix can't create files.
+Applying every fix of a file at once can leave its grr import unused, which goimports or grr gen removes.
+The grr.json at the module root applies.`
+
+// Analyzer reports the grr.Errorf calls of a package
+var Analyzer = &analysis.Analyzer{
+	Name: "grr",
+	Doc:  doc,
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	if len(pass.Files) == 0 {
+		return nil, nil
+	}
+
+	// the generator works on packages, which the pass has everything of
+	pkg := &packages.Package{
+		ID:        pass.Pkg.Path(),
+		Name:      pass.Pkg.Name(),
+		PkgPath:   pass.Pkg.Path(),
+		Fset:      pass.Fset,
+		Syntax:    pass.Files,
+		Types:     pass.Pkg,
+		TypesInfo: pass.TypesInfo,
+	}
+
+	for _, file := range pass.Files {
+		pkg.GoFiles = append(pkg.GoFiles, pass.Fset.File(file.Pos()).Name())
+	}
+
+	dir := filepath.Dir(pkg.GoFiles[0])
+	cfg, err := config.Discover(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Walks(dir) {
+		return nil, nil
+	}
+
+	diagnostics, fixes, err := gen.Analyze(pkg, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, diagnostic := range diagnostics {
+		pos := position(pass, diagnostic.File, diagnostic.Line, diagnostic.Column)
+
+		if !pos.IsValid() {
+			continue
+		}
+
+		report := analysis.Diagnostic{Pos: pos, Category: diagnostic.Code, Message: diagnostic.Message}
+
+		if diagnostic.SuggestedFix != "" {
+			report.Message += " (" + diagnostic.SuggestedFix + ")"
+		}
+
+		if fix, ok := suggestedFix(pass, fixes[idx]); ok {
+			report.SuggestedFixes = []analysis.SuggestedFix{fix}
+		} else if diagnostic.Code == "Converted" || diagnostic.Code == "Reused" {
+			report.Message += " (no fix until grr gen has created the generated file of the package)"
+		}
+
+		pass.Report(report)
+	}
+
+	return nil, nil
+}
+
+// suggestedFix returns the fix converting a call site. There is none when the call site has no fix,
+// or when one of its edits is outside of the files of the pass
+func suggestedFix(pass *analysis.Pass, siteFix *gen.SiteFix) (analysis.SuggestedFix, bool) {
+	if siteFix == nil {
+		return analysis.SuggestedFix{}, false
+	}
+
+	fix := analysis.SuggestedFix{Message: "Convert the grr.Errorf call to " + siteFix.ErrorName}
+
+	for _, edit := range siteFix.Edits {
+		start := offsetPos(pass, edit.File, edit.Start)
+		end := offsetPos(pass, edit.File, edit.End)
+
+		if !start.IsValid() || !end.IsValid() {
+			return analysis.SuggestedFix{}, false
+		}
+
+		fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: start, End: end, NewText: []byte(edit.NewText)})
+	}
+
+	return fix, true
+}
+
+// offsetPos returns the position of an offset in a file of the pass
+func offsetPos(pass *analysis.Pass, path string, offset int) token.Pos {
+	for _, file := range pass.Files {
+		tokFile := pass.Fset.File(file.Pos())
+
+		if tokFile.Name() == path && offset >= 0 && offset <= tokFile.Size() {
+			return tokFile.Pos(offset)
+		}
+	}
+
+	return token.NoPos
+}
+
+// position returns the position of a line and column in a file of the pass
+func position(pass *analysis.Pass, path string, line, column int) token.Pos {
+	for _, file := range pass.Files {
+		tokFile := pass.Fset.File(file.Pos())
+
+		if tokFile.Name() != path || line < 1 || line > tokFile.LineCount() {
+			continue
+		}
+
+		return tokFile.LineStart(line) + token.Pos(column-1)
+	}
+
+	return token.NoPos
+}
@@ -0,0 +1,12 @@
+package analysis
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// Package a has a generated file the fixes declare new errors in, and package b has none, so its calls have no fix
+func TestAnalyzer(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), Analyzer, "a", "b")
+}
@@ -0,0 +1,15 @@
+package a
+
+import "github.com/jackHedaya/grr/grr"
+
+func Find(name string) error {
+	return grr.Errorf("NotFound: %s was not found", name) // want `grr.Errorf call converts to the existing ErrNotFound`
+}
+
+func Dynamic(format string) error {
+	return grr.Errorf(format) // want `isn't a constant string`
+}
+
+func Unnamed() error {
+	return grr.Errorf("not found") // want `error name not found in error message`
+}
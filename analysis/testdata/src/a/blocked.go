@@ -0,0 +1,11 @@
+package a
+
+import (
+	"net/url"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+func Block(u *url.URL) error {
+	return grr.Errorf("Blocked: %v is blocked", u) // want `grr.Errorf call converts to ErrBlocked`
+}
@@ -0,0 +1,103 @@
+package a
+
+import (
+	"fmt"
+	"github.com/jackHedaya/grr/grr"
+	"log/slog"
+	"time"
+)
+
+// #############################################################################
+// # ErrNotFound
+// # checksum: 4cadd701edd7fdadbcc457753fee25ce9bb16be74303bdab4fb2465be0509f86
+// #############################################################################
+
+type ErrNotFound struct {
+	err     error
+	op      string
+	traits  map[grr.Trait]any
+	created time.Time
+	name    string
+}
+
+var _ grr.Error = &ErrNotFound{}
+
+func NewErrNotFound(name string) *ErrNotFound {
+	return &ErrNotFound{
+		traits:  map[grr.Trait]any{},
+		created: grr.Now(),
+		name:    name,
+	}
+}
+
+func (e *ErrNotFound) Error() string {
+	return fmt.Sprintf("%s was not found", e.name)
+}
+
+func (e *ErrNotFound) Name() string {
+	return e.name
+}
+
+func (e *ErrNotFound) Unwrap() error {
+	return e.err
+}
+
+func (e *ErrNotFound) UnwrapAll() error {
+	return grr.UnwrapAll(e)
+}
+
+func (e *ErrNotFound) AsGrr(err grr.Error) (grr.Error, bool) {
+	return grr.AsGrr(e, err)
+}
+
+func (e *ErrNotFound) AddTrait(trait grr.Trait, value any) grr.Error {
+	e.traits[trait] = value
+	return e
+}
+
+func (e *ErrNotFound) GetTrait(key grr.Trait) (any, bool) {
+	trait, ok := e.traits[key]
+	return trait, ok
+}
+
+func (e *ErrNotFound) GetTraits() map[grr.Trait]any {
+	traits := map[grr.Trait]any{}
+	for k, v := range e.traits {
+		traits[k] = v
+	}
+	return traits
+}
+
+func (e *ErrNotFound) AddOp(op string) grr.Error {
+	e.op = op
+	return e
+}
+
+func (e *ErrNotFound) GetOp() string {
+	return e.op
+}
+
+func (e *ErrNotFound) AddError(err error) grr.Error {
+	e.err = err
+	return e
+}
+
+func (e *ErrNotFound) CreatedAt() time.Time {
+	return e.created
+}
+
+func (e *ErrNotFound) LogValue() slog.Value {
+	return grr.LogValue(e)
+}
+
+func (e *ErrNotFound) MarshalJSON() ([]byte, error) {
+	return grr.MarshalJSON(e)
+}
+
+func (e *ErrNotFound) Trace() {
+	grr.Trace(e)
+}
+
+func (e *ErrNotFound) Strace() string {
+	return grr.Strace(e)
+}
@@ -0,0 +1,7 @@
+package b
+
+import "github.com/jackHedaya/grr/grr"
+
+func Find(name string) error {
+	return grr.Errorf("NotFound: %s was not found", name) // want `grr.Errorf call converts to ErrNotFound \(no fix until grr gen has created the generated file of the package\)`
+}
@@ -0,0 +1,37 @@
+// Package grr stubs the parts of grr that the test packages and their generated errors use
+package grr
+
+import (
+	"log/slog"
+	"time"
+)
+
+type Trait string
+
+type Error interface {
+	Error() string
+	Unwrap() error
+	AddTrait(trait Trait, value any) Error
+	AddOp(op string) Error
+	AddError(err error) Error
+}
+
+func Errorf(format string, args ...any) Error { return nil }
+
+func Sentinel(msg string) Error { return nil }
+
+func SentinelWithID(id string, msg string) Error { return nil }
+
+func Now() time.Time { return time.Time{} }
+
+func UnwrapAll(e Error) error { return nil }
+
+func AsGrr(e Error, err error) (Error, bool) { return nil, false }
+
+func LogValue(err error) slog.Value { return slog.Value{} }
+
+func MarshalJSON(err error) ([]byte, error) { return nil, nil }
+
+func Trace(err error) {}
+
+func Strace(err error) string { return "" }
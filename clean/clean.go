@@ -3,13 +3,16 @@ package clean
 import (
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/jackHedaya/grr/config"
 	"github.com/jackHedaya/grr/grr"
 	"github.com/jackHedaya/grr/utils"
 )
 
-// Deletes all grr.gen.go files in the directory.
-func CleanEntry(directory string) error {
+// Deletes all generated files in the directory, skipping the directories the config doesn't walk.
+// Errors packages left empty are deleted as well.
+func CleanEntry(directory string, cfg *config.Config) error {
 	dir, err := utils.ResolveAbsoluteDir(directory)
 
 	if err != nil {
@@ -25,12 +28,18 @@ func CleanEntry(directory string) error {
 			return filepath.SkipDir
 		}
 
-		if filepath.Base(path) == "grr.gen.go" {
+		if info.IsDir() {
+			return nil
+		}
+
+		if cfg.IsGenerated(path) && cfg.Walks(filepath.Dir(path)) {
 			err := os.Remove(path)
 
 			if err != nil {
 				return grr.Errorf("FailedToDelete: Failed to delete %s", path).AddError(err)
 			}
+
+			removeEmptyErrorsPackage(filepath.Dir(path), cfg)
 		}
 
 		return nil
@@ -42,3 +51,19 @@ func CleanEntry(directory string) error {
 
 	return nil
 }
+
+// removeEmptyErrorsPackage deletes dir if it is an errors package that no longer has any file, along with its empty parents up to the package
+func removeEmptyErrorsPackage(dir string, cfg *config.Config) {
+	if cfg.Layout != config.LayoutSubpackage || !strings.HasSuffix(dir, string(filepath.Separator)+filepath.Clean(cfg.ErrorsPackage)) {
+		return
+	}
+
+	pkgDir := strings.TrimSuffix(dir, string(filepath.Separator)+filepath.Clean(cfg.ErrorsPackage))
+
+	for ; dir != pkgDir; dir = filepath.Dir(dir) {
+		// only succeeds when the directory is empty
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
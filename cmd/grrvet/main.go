@@ -0,0 +1,12 @@
+// grrvet reports grr.Errorf calls that aren't generated errors yet. Run it on packages like any checker, e.g. grrvet -fix ./...,
+// or through go vet with go vet -vettool=$(which grrvet) ./...
+package main
+
+import (
+	"github.com/jackHedaya/grr/analysis"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analysis.Analyzer)
+}
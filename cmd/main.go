@@ -1,11 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"slices"
+	"strings"
 
 	"github.com/jackHedaya/grr/clean"
+	"github.com/jackHedaya/grr/config"
 	"github.com/jackHedaya/grr/gen"
 	"github.com/jackHedaya/grr/grr"
 )
@@ -30,6 +36,8 @@ func main() {
 		genCmd(subCmdArgs)
 	case "clean":
 		cleanCmd(subCmdArgs)
+	case "check":
+		checkCmd(subCmdArgs)
 	case "help":
 		helpCmd()
 	default:
@@ -40,13 +48,31 @@ func main() {
 // The gen subcommand is used to generate error structs and functions
 // subArgs is the arguments passed to the gen subcommand (e.g., ./grr gen <subArgs>)
 func genCmd(subArgs []string) {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	prune := flags.Bool("prune", false, "remove generated errors that are no longer referenced")
+	force := flags.Bool("force", false, "with --prune, also remove errors other modules may import")
+	dryRun := flags.Bool("dry-run", false, "print a diff of the changes instead of writing them, exiting 1 if there are any")
+	typeCheck := flags.Bool("typecheck", false, "type-check the changed packages before writing anything")
+	configPath := flags.String("config", "", "path to the config file, instead of the grr.json at the module root")
+	asJSON := flags.Bool("json", false, "print the diagnostics as JSON instead of file:line:col lines")
+	flags.Parse(subArgs)
 
-	if len(subArgs) != 1 {
-		fmt.Println("Usage: grr gen <folder>")
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: grr gen [--config <file>] [--json] [--dry-run] [--typecheck] [--prune [--force]] <folder>")
+		os.Exit(1)
+	}
+
+	if *dryRun && *prune {
+		fmt.Println("--dry-run can't be combined with --prune")
+		os.Exit(1)
+	}
+
+	if *asJSON && (*dryRun || *prune) {
+		fmt.Println("--json can't be combined with --dry-run or --prune")
 		os.Exit(1)
 	}
 
-	dirName := subArgs[0]
+	dirName := flags.Arg(0)
 
 	if isDir, err := isDir(dirName); !isDir {
 		fmt.Println("Usage: grr gen <folder>")
@@ -56,23 +82,86 @@ func genCmd(subArgs []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Finding and replacing grr.Errorf calls in %s/...\n", path.Join(dirName, "..."))
+	cfg := loadConfig(*configPath, dirName)
+
+	opts := gen.GenerateOptions{DryRun: *dryRun, TypeCheck: *typeCheck, Config: cfg}
+
+	if *asJSON {
+		opts.Log = io.Discard
+	} else {
+		fmt.Printf("Finding and replacing grr.Errorf calls in %s/...\n", path.Join(dirName, "..."))
+	}
+
 	// Find and replace grr.Errorf calls in the file
-	err := gen.GenerateEntry(dirName)
+	changed, diagnostics, err := gen.GenerateEntry(dirName, opts)
+
+	if *asJSON {
+		result := map[string]any{
+			"changed":     changed,
+			"diagnostics": diagnostics,
+		}
+
+		if err != nil {
+			result["error"] = err.Error()
+		}
+
+		out, _ := json.MarshalIndent(result, "", "  ")
+
+		fmt.Println(string(out))
+	} else {
+		for _, diagnostic := range diagnostics {
+			fmt.Println(diagnostic)
+		}
+	}
 
 	if err != nil {
-		fmt.Printf("Error generating error structs: %s\n", grr.Strace(err))
+		if !*asJSON {
+			fmt.Printf("Error generating error structs: %s\n", grr.Strace(err))
+		}
+
+		os.Exit(1)
+	}
+
+	// call sites that couldn't be converted fail the run, like a dry run that would change files
+	failed := slices.ContainsFunc(diagnostics, func(d gen.Diagnostic) bool { return d.Severity == gen.SeverityError })
+
+	if *dryRun && changed {
+		failed = true
+	}
+
+	if *prune {
+		pruned, pruneDiagnostics, err := gen.PruneEntry(dirName, *force, cfg)
+
+		for _, diagnostic := range pruneDiagnostics {
+			fmt.Println(diagnostic)
+		}
+
+		if err != nil {
+			fmt.Printf("Error pruning generated errors: %s\n", grr.Strace(err))
+			os.Exit(1)
+		}
+
+		for _, p := range pruned {
+			fmt.Printf("Pruned %s from %s\n", p.Name, p.PkgPath)
+		}
+	}
+
+	if failed {
 		os.Exit(1)
 	}
 }
 
 func cleanCmd(args []string) {
-	if len(args) != 1 {
-		fmt.Println("Usage: grr clean <folder>")
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	configPath := flags.String("config", "", "path to the config file, instead of the grr.json at the module root")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: grr clean [--config <file>] <folder>")
 		os.Exit(1)
 	}
 
-	dirName := args[0]
+	dirName := flags.Arg(0)
 
 	if isDir, err := isDir(dirName); !isDir {
 		fmt.Println("Usage: grr gen <folder>")
@@ -82,8 +171,10 @@ func cleanCmd(args []string) {
 		os.Exit(1)
 	}
 
+	cfg := loadConfig(*configPath, dirName)
+
 	fmt.Printf("Cleaning up grr.Errorf calls in %s\n", path.Join(dirName, "..."))
-	err := clean.CleanEntry(dirName)
+	err := clean.CleanEntry(dirName, cfg)
 
 	if err != nil {
 		fmt.Printf("Error cleaning up grr.Errorf calls: %s\n", grr.Strace(err))
@@ -91,11 +182,110 @@ func cleanCmd(args []string) {
 	}
 }
 
+// The check subcommand verifies that generation is complete and up to date, e.g. in CI
+func checkCmd(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the problems and their summary as JSON")
+	configPath := flags.String("config", "", "path to the config file, instead of the grr.json at the module root")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: grr check [--config <file>] [--json] <folder>")
+		os.Exit(1)
+	}
+
+	dirName := flags.Arg(0)
+
+	if isDir, err := isDir(dirName); !isDir {
+		fmt.Println("Usage: grr check [--json] <folder>")
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Println("Error checking if path is a directory:", err)
+		os.Exit(1)
+	}
+
+	cfg := loadConfig(*configPath, dirName)
+
+	problems, err := gen.CheckEntry(dirName, cfg)
+
+	if err != nil {
+		fmt.Printf("Error checking generated errors: %s\n", grr.Strace(err))
+		os.Exit(1)
+	}
+
+	printProblems(os.Stdout, problems, *asJSON)
+
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// printProblems writes the problems found by grr check to w, followed by a summary of their kinds, or both as JSON
+func printProblems(w io.Writer, problems []gen.CheckProblem, asJSON bool) {
+	summary := map[string]int{}
+
+	for _, problem := range problems {
+		summary[problem.Kind]++
+	}
+
+	if asJSON {
+		out, _ := json.MarshalIndent(map[string]any{
+			"problems": problems,
+			"summary":  summary,
+		}, "", "  ")
+
+		fmt.Fprintln(w, string(out))
+
+		return
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintln(w, problem)
+	}
+
+	line := []string{fmt.Sprintf("%d problems", len(problems))}
+
+	for kind, count := range summary {
+		line = append(line, fmt.Sprintf("%s=%d", kind, count))
+	}
+
+	slices.Sort(line[1:])
+
+	fmt.Fprintln(w, strings.Join(line, " "))
+}
+
+// loadConfig loads the config file at configPath, or the grr.json at the module root of dirName when configPath is empty
+func loadConfig(configPath string, dirName string) *config.Config {
+	var cfg *config.Config
+	var err error
+
+	if configPath != "" {
+		cfg, err = config.Load(configPath)
+	} else {
+		cfg, err = config.Discover(dirName)
+	}
+
+	if err != nil {
+		fmt.Printf("Error loading config: %s\n", grr.Strace(err))
+		os.Exit(1)
+	}
+
+	return cfg
+}
+
 func helpCmd() {
 	fmt.Println("Usage: grr <command> [<args>]")
+	fmt.Println("Every command takes --config <file> to use instead of the grr.json at the module root")
 	fmt.Println("Commands:")
 	fmt.Println("  gen <folder>    Find and replace grr.Errorf calls in the specified folder")
+	fmt.Println("    --dry-run     Print a diff of the changes instead of writing them")
+	fmt.Println("    --typecheck   Type-check the changed packages before writing anything")
+	fmt.Println("    --json        Print the diagnostics as JSON")
+	fmt.Println("    --prune       Remove generated errors that are no longer referenced")
+	fmt.Println("    --force       With --prune, also remove errors other modules may import")
 	fmt.Println("  clean <folder>  Clean up grr.Errorf calls in the specified folder")
+	fmt.Println("  check <folder>  Verify that generation is complete and up to date")
+	fmt.Println("    --json        Print the problems and their summary as JSON")
 	fmt.Println("  help            Display this help message")
 }
 
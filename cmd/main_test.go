@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/gen"
+)
+
+func TestPrintProblems(t *testing.T) {
+	problems := []gen.CheckProblem{
+		{File: "a.go", Line: 3, Column: 9, Kind: gen.ProblemStale, Message: "ErrTimeout is no longer used"},
+		{File: "b.go", Line: 7, Column: 2, Kind: gen.ProblemUnconverted, Message: "grr.Errorf call for ErrNotFound has not been generated"},
+		{File: "b.go", Line: 9, Column: 2, Kind: gen.ProblemUnconverted, Message: "grr.Errorf call for ErrNotFound has not been generated"},
+	}
+
+	tests := []struct {
+		name     string
+		problems []gen.CheckProblem
+		want     string
+	}{
+		{name: "none", problems: nil, want: "0 problems\n"},
+		{
+			name:     "summary",
+			problems: problems,
+			want: "a.go:3:9: stale: ErrTimeout is no longer used\n" +
+				"b.go:7:2: unconverted: grr.Errorf call for ErrNotFound has not been generated\n" +
+				"b.go:9:2: unconverted: grr.Errorf call for ErrNotFound has not been generated\n" +
+				"3 problems stale=1 unconverted=2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+
+			printProblems(&out, test.problems, false)
+
+			if out.String() != test.want {
+				t.Errorf("got %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestPrintProblemsJSON(t *testing.T) {
+	problems := []gen.CheckProblem{
+		{File: "a.go", Line: 3, Column: 9, Kind: gen.ProblemStale, Message: "ErrTimeout is no longer used"},
+		{File: "b.go", Line: 7, Column: 2, Kind: gen.ProblemUnconverted, Message: "grr.Errorf call for ErrNotFound has not been generated"},
+	}
+
+	var out strings.Builder
+
+	printProblems(&out, problems, true)
+
+	var got struct {
+		Problems []gen.CheckProblem `json:"problems"`
+		Summary  map[string]int     `json:"summary"`
+	}
+
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+
+	if !reflect.DeepEqual(got.Problems, problems) {
+		t.Errorf("got problems %v, want %v", got.Problems, problems)
+	}
+
+	if want := map[string]int{gen.ProblemStale: 1, gen.ProblemUnconverted: 1}; !reflect.DeepEqual(got.Summary, want) {
+		t.Errorf("got summary %v, want %v", got.Summary, want)
+	}
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+// FileName is the name of the config file looked up at the module root
+const FileName = "grr.json"
+
+// Layouts of the generated code
+const (
+	// One OutputFile in each package
+	LayoutPackage = "package"
+	// One generated file next to each source file with grr.Errorf calls, e.g. foo_grr.gen.go for foo.go
+	LayoutFile = "file"
+	// One OutputFile in the ErrorsPackage of each package, which the call sites import
+	LayoutSubpackage = "subpackage"
+)
+
+// Config is the project configuration shared by gen, clean and check
+type Config struct {
+	// Prefix of every generated error name, e.g. Err for ErrFileNotFound
+	ErrorPrefix string `json:"errorPrefix"`
+	// Pattern matching error messages. The first group is the error name and the second the message
+	NamePattern string `json:"namePattern"`
+	// Import paths of the grr package
+	ImportPaths []string `json:"importPaths"`
+	// Name of the function whose calls are converted
+	Errorf string `json:"errorf"`
+	// Name of the generated file in each package
+	OutputFile string `json:"outputFile"`
+	// Where the generated code goes, one of the Layout constants
+	Layout string `json:"layout"`
+	// Directory of the errors package relative to each package, for LayoutSubpackage
+	ErrorsPackage string `json:"errorsPackage"`
+	// Directories to process, relative to Root. Everything is processed when empty
+	Include []string `json:"include,omitempty"`
+	// Directories to skip, relative to Root
+	Exclude []string `json:"exclude,omitempty"`
+	// Add the op of the enclosing function, e.g. pkg.Type.Method, to call sites that don't add one
+	AutoOp bool `json:"autoOp"`
+	// Export the fields of generated errors instead of generating a getter for each of them
+	ExportFields bool `json:"exportFields,omitempty"`
+	// Templates replacing the embedded ones
+	Templates TemplateFiles `json:"templates,omitempty"`
+
+	// The directory the config applies to: where grr.json was found, or the module root
+	Root string `json:"-"`
+
+	namePattern *regexp.Regexp
+}
+
+// TemplateFiles are paths to custom templates, relative to Root. The embedded template is used for every path left empty
+type TemplateFiles struct {
+	// Template of an error with arguments, executed with gen.StructTemplateData
+	Struct string `json:"struct,omitempty"`
+	// Template of an error without arguments, executed with gen.StructTemplateData
+	Sentinel string `json:"sentinel,omitempty"`
+	// Template of the generated file, executed with gen.HeaderTemplateData
+	File string `json:"file,omitempty"`
+	// The template data version the templates were written for. When set, it must be the version grr generates with
+	Version int `json:"version,omitempty"`
+}
+
+// Path resolves a template path relative to Root
+func (c *Config) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(c.Root, path)
+}
+
+// Default returns the configuration used when there is no grr.json
+func Default() *Config {
+	cfg := &Config{
+		ErrorPrefix: "Err",
+		NamePattern: `^([A-Z][a-zA-Z]+):\s(.*)`,
+		ImportPaths: []string{
+			"grr/grr",
+			"github.com/jackHedaya/grr",
+			"github.com/jackHedaya/grr/grr",
+		},
+		Errorf:        "Errorf",
+		OutputFile:    "grr.gen.go",
+		Layout:        LayoutPackage,
+		ErrorsPackage: "internal/errs",
+		AutoOp:        true,
+	}
+
+	cfg.namePattern = regexp.MustCompile(cfg.NamePattern)
+
+	return cfg
+}
+
+// Load reads the config file at path. Missing settings keep their default values
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToReadConfig: failed to read config %s", path).AddError(err)
+	}
+
+	cfg := Default()
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, grr.Errorf("FailedToParseConfig: failed to parse config %s", path).AddError(err)
+	}
+
+	absPath, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToResolveDir: failed to resolve absolute path").AddError(err)
+	}
+
+	cfg.Root = filepath.Dir(absPath)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, grr.Errorf("InvalidConfig: %s is not a valid config", path).AddError(err)
+	}
+
+	return cfg, nil
+}
+
+// Discover finds the module root above dir and loads its grr.json, falling back to the defaults if there is none
+func Discover(dir string) (*Config, error) {
+	absDir, err := filepath.Abs(dir)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToResolveDir: failed to resolve absolute path").AddError(err)
+	}
+
+	root := absDir
+
+	for {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+			break
+		}
+
+		parent := filepath.Dir(root)
+
+		// not in a module, the config applies to dir itself
+		if parent == root {
+			root = absDir
+			break
+		}
+
+		root = parent
+	}
+
+	path := filepath.Join(root, FileName)
+
+	if _, err := os.Stat(path); err == nil {
+		return Load(path)
+	}
+
+	cfg := Default()
+	cfg.Root = root
+
+	return cfg, nil
+}
+
+// Validate checks every setting and returns an error describing the first invalid one
+func (c *Config) Validate() error {
+	if !token.IsIdentifier(c.ErrorPrefix) {
+		return grr.Errorf("InvalidErrorPrefix: errorPrefix %q must be a Go identifier", c.ErrorPrefix)
+	}
+
+	namePattern, err := regexp.Compile(c.NamePattern)
+
+	if err != nil {
+		return grr.Errorf("FailedToCompileNamePattern: namePattern %q does not compile", c.NamePattern).AddError(err)
+	}
+
+	if namePattern.NumSubexp() < 2 {
+		return grr.Errorf("InvalidNamePattern: namePattern %q needs a group for the error name and one for the message", c.NamePattern)
+	}
+
+	if len(c.ImportPaths) == 0 {
+		return grr.Errorf("NoImportPaths: importPaths must list at least one import path of grr")
+	}
+
+	for _, path := range c.ImportPaths {
+		if path == "" || strings.ContainsAny(path, " \t\"") {
+			return grr.Errorf("InvalidImportPath: %q is not a valid import path", path)
+		}
+	}
+
+	if !token.IsIdentifier(c.Errorf) || !token.IsExported(c.Errorf) {
+		return grr.Errorf("InvalidErrorf: errorf %q must be an exported Go identifier", c.Errorf)
+	}
+
+	if filepath.Base(c.OutputFile) != c.OutputFile || !strings.HasSuffix(c.OutputFile, ".go") || strings.HasSuffix(c.OutputFile, "_test.go") {
+		return grr.Errorf("InvalidOutputFile: outputFile %q must be a non-test .go file name without directories", c.OutputFile)
+	}
+
+	if !slices.Contains([]string{LayoutPackage, LayoutFile, LayoutSubpackage}, c.Layout) {
+		return grr.Errorf("InvalidLayout: layout %q must be one of package, file or subpackage", c.Layout)
+	}
+
+	errorsPackage := filepath.Clean(c.ErrorsPackage)
+
+	if filepath.IsAbs(errorsPackage) || strings.HasPrefix(errorsPackage, "..") || !token.IsIdentifier(filepath.Base(errorsPackage)) {
+		return grr.Errorf("InvalidErrorsPackage: errorsPackage %q must be a directory inside the package named like a Go package", c.ErrorsPackage)
+	}
+
+	for _, dir := range slices.Concat(c.Include, c.Exclude) {
+		if dir == "" || filepath.IsAbs(dir) || strings.HasPrefix(filepath.Clean(dir), "..") {
+			return grr.Errorf("InvalidDirectory: %q must be a directory inside the module", dir)
+		}
+	}
+
+	if c.Templates.Version < 0 {
+		return grr.Errorf("InvalidTemplateVersion: templates.version %d must be positive", c.Templates.Version)
+	}
+
+	c.namePattern = namePattern
+
+	return nil
+}
+
+// IsGenerated reports whether the file at path was generated, in any layout
+func (c *Config) IsGenerated(path string) bool {
+	base := filepath.Base(path)
+
+	return base == c.OutputFile || strings.HasSuffix(base, "_"+c.OutputFile)
+}
+
+// FileOutput returns the generated file of a source file for LayoutFile, e.g. foo_grr.gen.go for foo.go
+func (c *Config) FileOutput(source string) string {
+	return filepath.Join(filepath.Dir(source), strings.TrimSuffix(filepath.Base(source), ".go")+"_"+c.OutputFile)
+}
+
+// NameRegexp returns the compiled NamePattern
+func (c *Config) NameRegexp() *regexp.Regexp {
+	if c.namePattern == nil {
+		c.namePattern = regexp.MustCompile(c.NamePattern)
+	}
+
+	return c.namePattern
+}
+
+// Walks reports whether the directory dir should be processed according to Include and Exclude
+func (c *Config) Walks(dir string) bool {
+	rel, err := filepath.Rel(c.Root, dir)
+
+	if err != nil || c.Root == "" {
+		return true
+	}
+
+	for _, exclude := range c.Exclude {
+		if isWithin(rel, exclude) {
+			return false
+		}
+	}
+
+	if len(c.Include) == 0 {
+		return true
+	}
+
+	for _, include := range c.Include {
+		if isWithin(rel, include) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWithin reports whether the relative path rel is dir or inside of it
+func isWithin(rel, dir string) bool {
+	dir = filepath.Clean(dir)
+
+	return dir == "." || rel == dir || strings.HasPrefix(rel, dir+string(filepath.Separator))
+}
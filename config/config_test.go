@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+// writeFile writes content to the file at path, creating its directory
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "go.mod"), "module example.com/proj\n")
+	writeFile(t, filepath.Join(root, "a", "b", "b.go"), "package b\n")
+
+	cfg, err := Discover(filepath.Join(root, "a", "b"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Root != root || cfg.ErrorPrefix != Default().ErrorPrefix {
+		t.Errorf("without grr.json got root %s and prefix %q, want the defaults at %s", cfg.Root, cfg.ErrorPrefix, root)
+	}
+
+	writeFile(t, filepath.Join(root, FileName), `{"errorPrefix": "E", "layout": "file", "exclude": ["a/b"]}`)
+
+	cfg, err = Discover(filepath.Join(root, "a", "b"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Root != root || cfg.ErrorPrefix != "E" || cfg.Layout != LayoutFile || !reflect.DeepEqual(cfg.Exclude, []string{"a/b"}) {
+		t.Errorf("got %+v, want the settings of grr.json at %s", cfg, root)
+	}
+
+	// settings missing from grr.json keep their defaults
+	if cfg.OutputFile != Default().OutputFile || !reflect.DeepEqual(cfg.ImportPaths, Default().ImportPaths) {
+		t.Errorf("got outputFile %q and importPaths %v, want the defaults", cfg.OutputFile, cfg.ImportPaths)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantID  string
+	}{
+		{name: "valid", content: `{"namePattern": "^([A-Z]\\w+) - (.*)", "autoOp": false}`},
+		{name: "unknown setting", content: `{"prefix": "Err"}`, wantID: "FailedToParseConfig"},
+		{name: "invalid JSON", content: `{"layout": }`, wantID: "FailedToParseConfig"},
+		{name: "invalid setting", content: `{"layout": "tree"}`, wantID: "InvalidConfig"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), FileName)
+			writeFile(t, path, test.content)
+
+			cfg, err := Load(path)
+
+			if grr.ID(err) != test.wantID {
+				t.Fatalf("got error %v, want %q", err, test.wantID)
+			}
+
+			if err == nil && (cfg.Root != filepath.Dir(path) || cfg.NameRegexp().String() != `^([A-Z]\w+) - (.*)` || cfg.AutoOp) {
+				t.Errorf("got %+v, want the settings of %s", cfg, path)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), FileName)); grr.ID(err) != "FailedToReadConfig" {
+		t.Errorf("got error %v for a missing config, want FailedToReadConfig", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(cfg *Config)
+		wantID string
+	}{
+		{name: "default", edit: func(cfg *Config) {}},
+		{name: "error prefix", edit: func(cfg *Config) { cfg.ErrorPrefix = "1Err" }, wantID: "InvalidErrorPrefix"},
+		{name: "name pattern", edit: func(cfg *Config) { cfg.NamePattern = "(" }, wantID: "FailedToCompileNamePattern"},
+		{name: "name pattern groups", edit: func(cfg *Config) { cfg.NamePattern = "^([A-Z]+): .*" }, wantID: "InvalidNamePattern"},
+		{name: "no import paths", edit: func(cfg *Config) { cfg.ImportPaths = nil }, wantID: "NoImportPaths"},
+		{name: "import path", edit: func(cfg *Config) { cfg.ImportPaths = []string{"my grr"} }, wantID: "InvalidImportPath"},
+		{name: "errorf", edit: func(cfg *Config) { cfg.Errorf = "errorf" }, wantID: "InvalidErrorf"},
+		{name: "output file", edit: func(cfg *Config) { cfg.OutputFile = "gen/grr.gen.go" }, wantID: "InvalidOutputFile"},
+		{name: "test output file", edit: func(cfg *Config) { cfg.OutputFile = "grr_test.go" }, wantID: "InvalidOutputFile"},
+		{name: "layout", edit: func(cfg *Config) { cfg.Layout = "tree" }, wantID: "InvalidLayout"},
+		{name: "errors package", edit: func(cfg *Config) { cfg.ErrorsPackage = "../errs" }, wantID: "InvalidErrorsPackage"},
+		{name: "errors package name", edit: func(cfg *Config) { cfg.ErrorsPackage = "internal/my-errs" }, wantID: "InvalidErrorsPackage"},
+		{name: "include", edit: func(cfg *Config) { cfg.Include = []string{"/abs"} }, wantID: "InvalidDirectory"},
+		{name: "exclude", edit: func(cfg *Config) { cfg.Exclude = []string{"../other"} }, wantID: "InvalidDirectory"},
+		{name: "template version", edit: func(cfg *Config) { cfg.Templates.Version = -1 }, wantID: "InvalidTemplateVersion"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := Default()
+			test.edit(cfg)
+
+			if err := cfg.Validate(); grr.ID(err) != test.wantID {
+				t.Errorf("got error %v, want %q", err, test.wantID)
+			}
+		})
+	}
+}
+
+func TestWalks(t *testing.T) {
+	root := filepath.FromSlash("/proj")
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		walked  []string
+		skipped []string
+	}{
+		{name: "everything", walked: []string{".", "a", "a/b"}},
+		{name: "include", include: []string{"a"}, walked: []string{"a", "a/b"}, skipped: []string{".", "ab", "c"}},
+		{name: "exclude", exclude: []string{"a/b"}, walked: []string{".", "a", "a/bc"}, skipped: []string{"a/b", "a/b/c"}},
+		{name: "exclude within include", include: []string{"a"}, exclude: []string{"a/b"}, walked: []string{"a"}, skipped: []string{"a/b", "c"}},
+		{name: "root", exclude: []string{"."}, skipped: []string{".", "a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Root = root
+			cfg.Include = test.include
+			cfg.Exclude = test.exclude
+
+			for _, dir := range test.walked {
+				if !cfg.Walks(filepath.Join(root, filepath.FromSlash(dir))) {
+					t.Errorf("%s is skipped, want it walked", dir)
+				}
+			}
+
+			for _, dir := range test.skipped {
+				if cfg.Walks(filepath.Join(root, filepath.FromSlash(dir))) {
+					t.Errorf("%s is walked, want it skipped", dir)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package gen
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"github.com/jackHedaya/grr/utils"
+)
+
+// setAccessors names the struct field and getter of every field. Getters are named after their field, e.g. Path() for path,
+// falling back to GetPath() and then numbered names when that collides with a field or a method of the error.
+// Exported fields can't share a name with a method either, and fall back to PathValue
+func setAccessors(vars []GrrGenErrorField, exportFields bool) {
+	taken := utils.NewSet[string]()
+
+	for _, name := range reservedFieldNames {
+		// the intrinsic fields are unexported, so only the methods can collide
+		if token.IsExported(name) {
+			taken.Add(name)
+		}
+	}
+
+	for _, v := range vars {
+		taken.Add(v.Name)
+	}
+
+	for idx := range vars {
+		name := vars[idx].Name
+
+		// an exported field can keep its own name, a getter can't
+		isFree := func(accessor string) bool {
+			return !taken.Has(accessor) || (exportFields && accessor == name)
+		}
+
+		// exported fields read better with a suffix than as GetPath
+		fallback := "Get" + exportedName(name)
+
+		if exportFields {
+			fallback = exportedName(name) + "Value"
+		}
+
+		accessor := exportedName(name)
+
+		if !isFree(accessor) {
+			accessor = fallback
+		}
+
+		for n := 2; !isFree(accessor); n++ {
+			accessor = fmt.Sprintf("%s%d", fallback, n)
+		}
+
+		if exportFields {
+			vars[idx].Field = accessor
+			vars[idx].Getter = ""
+		} else {
+			vars[idx].Field = name
+			vars[idx].Getter = accessor
+		}
+
+		taken.Add(accessor)
+	}
+}
+
+// commonInitialisms are written in all capitals in exported names, like golint wants them
+var commonInitialisms = []string{
+	"ACL", "API", "ASCII", "CPU", "CSS", "DNS", "EOF", "GUID", "HTML", "HTTP", "HTTPS", "ID", "IP", "JSON", "LHS",
+	"QPS", "RAM", "RHS", "RPC", "SLA", "SMTP", "SQL", "SSH", "TCP", "TLS", "TTL", "UDP", "UI", "UID", "UUID", "URI",
+	"URL", "UTF8", "VM", "XML", "XMPP", "XSRF", "XSS",
+}
+
+// exportedName capitalizes every word of a field name, and writes initialisms in all capitals, e.g. ID for id and UserURL for userUrl
+func exportedName(name string) string {
+	words := splitWords(name)
+
+	for idx, word := range words {
+		if upper := strings.ToUpper(word); utils.Contains(commonInitialisms, upper) {
+			words[idx] = upper
+		} else {
+			words[idx] = title(word)
+		}
+	}
+
+	return strings.Join(words, "")
+}
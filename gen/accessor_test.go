@@ -0,0 +1,107 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/config"
+)
+
+func TestExportedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "path", want: "Path"},
+		{name: "id", want: "ID"},
+		{name: "url", want: "URL"},
+		{name: "userId", want: "UserID"},
+		{name: "userID", want: "UserID"},
+		{name: "httpUrl", want: "HTTPURL"},
+		{name: "jsonBody", want: "JSONBody"},
+		{name: "idea", want: "Idea"},
+		{name: "e2", want: "E2"},
+		{name: "file_name", want: "FileName"},
+	}
+
+	for _, test := range tests {
+		if got := exportedName(test.name); got != test.want {
+			t.Errorf("exportedName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSetAccessors(t *testing.T) {
+	tests := []struct {
+		name         string
+		vars         []string
+		exportFields bool
+		want         []string
+	}{
+		{name: "getters", vars: []string{"path", "id"}, want: []string{"Path", "ID"}},
+		{name: "method", vars: []string{"error", "trace"}, want: []string{"GetError", "GetTrace"}},
+		{name: "taken", vars: []string{"ID", "id"}, want: []string{"GetID", "GetID2"}},
+		{name: "exported fields", vars: []string{"url", "error"}, exportFields: true, want: []string{"URL", "ErrorValue"}},
+		{name: "exported field named like another", vars: []string{"userId", "UserID"}, exportFields: true, want: []string{"UserIDValue", "UserID"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vars := []GrrGenErrorField{}
+
+			for _, name := range test.vars {
+				vars = append(vars, GrrGenErrorField{Name: name})
+			}
+
+			setAccessors(vars, test.exportFields)
+
+			for idx, v := range vars {
+				accessor := v.Getter
+
+				if test.exportFields {
+					accessor = v.Field
+				}
+
+				if accessor != test.want[idx] {
+					t.Errorf("%s: got %s, want %s", v.Name, accessor, test.want[idx])
+				}
+			}
+		})
+	}
+}
+
+const accessorMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(id int, url string, userId string) error {
+	return grr.Errorf("NotFound: %d at %s for %s", id, url, userId)
+}
+`
+
+// Getters of generated errors write initialisms in all capitals, and so do exported fields
+func TestAccessors(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": accessorMain})
+
+	assertConverted(t, generate(t, dir))
+
+	generated := readFile(t, dir, "grr.gen.go")
+
+	for _, want := range []string{"func (e *ErrNotFound) ID() int {", "func (e *ErrNotFound) URL() string {", "func (e *ErrNotFound) UserID() string {"} {
+		if !strings.Contains(generated, want) {
+			t.Errorf("grr.gen.go doesn't contain %s:\n%s", want, generated)
+		}
+	}
+
+	dir = writeModule(t, map[string]string{"main.go": accessorMain})
+	cfg := layoutConfig(dir, config.LayoutPackage)
+	cfg.ExportFields = true
+
+	assertConverted(t, generateWith(t, dir, cfg))
+
+	if generated := readFile(t, dir, "grr.gen.go"); !strings.Contains(generated, "\tID      int\n") || !strings.Contains(generated, "\tUserID  string\n") {
+		t.Errorf("grr.gen.go doesn't export the fields with initialisms:\n%s", generated)
+	}
+}
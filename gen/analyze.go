@@ -0,0 +1,215 @@
+package gen
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/grr"
+	"golang.org/x/tools/go/packages"
+)
+
+// TextEdit replaces the bytes of File between the offsets Start and End with NewText
+type TextEdit struct {
+	File    string
+	Start   int
+	End     int
+	NewText string
+}
+
+// SiteFix converts a call site on its own
+type SiteFix struct {
+	// The error the call site converts to
+	ErrorName string
+	Edits     []TextEdit
+}
+
+// Analyze runs the generator over a type-checked package without writing anything, e.g. for the grr analyzer.
+// It returns the diagnostics of the call sites of the package, along with the fix of each call site at the same index.
+// Fixes are nil for call sites that aren't converted, and for those whose new error would have to be declared in a file
+// that isn't part of the package, e.g. before grr gen created grr.gen.go
+func Analyze(pkg *packages.Package, cfg *config.Config) ([]Diagnostic, []*SiteFix, error) {
+	tmpls, err := LoadTemplates(cfg)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkgWalker, fileToAst, err := walkPackage(pkg, cfg, tmpls, io.Discard)
+
+	if err != nil || pkgWalker == nil {
+		return nil, nil, err
+	}
+
+	layout, err := newOutputLayout(pkg, cfg)
+
+	if err != nil {
+		return pkgWalker.diagnostics, nil, err
+	}
+
+	// the errors of the converted call sites, by position
+	converted := map[string]GeneratedError{}
+
+	for _, genErr := range pkgWalker.generatedErrors {
+		converted[genErr.Pos.String()] = genErr
+	}
+
+	for _, genErr := range pkgWalker.reusedCalls {
+		converted[genErr.Pos.String()] = genErr
+	}
+
+	fixes := make([]*SiteFix, len(pkgWalker.diagnostics))
+
+	for idx, diagnostic := range pkgWalker.diagnostics {
+		if diagnostic.Code != "Converted" && diagnostic.Code != "Reused" {
+			continue
+		}
+
+		pos := token.Position{Filename: diagnostic.File, Line: diagnostic.Line, Column: diagnostic.Column}
+		genErr, ok := converted[pos.String()]
+
+		if !ok {
+			continue
+		}
+
+		edits, err := pkgWalker.siteEdits(layout, fileToAst[genErr.Pos.Filename], genErr)
+
+		if err != nil {
+			return pkgWalker.diagnostics, nil, err
+		}
+
+		if edits != nil {
+			fixes[idx] = &SiteFix{ErrorName: genErr.Name, Edits: edits}
+		}
+	}
+
+	return pkgWalker.diagnostics, fixes, nil
+}
+
+// siteEdits returns the edits converting the call site of genErr on its own, including those of the imports of its file.
+// A new error is declared in its generated file, and there are no edits when that file isn't part of the package
+func (walker *grrWalker) siteEdits(layout *outputLayout, file *ast.File, genErr GeneratedError) ([]TextEdit, error) {
+	path := genErr.Pos.Filename
+	edits := []textEdit{}
+
+	for _, edit := range walker.edits[path] {
+		if walker.fset.Position(edit.call).String() == genErr.Pos.String() {
+			edits = append(edits, edit)
+		}
+	}
+
+	src, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToRead: failed to read %s", path).AddError(err)
+	}
+
+	siteEdits := exportEdits(path, append(edits, walker.siteImportEdits(file, src, edits)...))
+
+	if _, ok := walker.prevErrors[genErr.Name]; ok {
+		return siteEdits, nil
+	}
+
+	declEdits, err := walker.declarationEdits(layout, genErr)
+
+	if err != nil || declEdits == nil {
+		return nil, err
+	}
+
+	return append(siteEdits, declEdits...), nil
+}
+
+// siteImportEdits returns the import edits of file for when only the call site of edits is converted,
+// so that the grr import is kept as long as other calls use it
+func (walker *grrWalker) siteImportEdits(file *ast.File, src []byte, edits []textEdit) []textEdit {
+	tokFile := walker.fset.File(file.Pos())
+	replaced := walker.replaced
+
+	defer func() {
+		walker.replaced = replaced
+	}()
+
+	walker.replaced = replaced.Filter(func(ident *ast.Ident) bool {
+		if walker.fset.File(ident.Pos()) != tokFile {
+			return false
+		}
+
+		offset := tokFile.Offset(ident.Pos())
+
+		return slices.ContainsFunc(edits, func(edit textEdit) bool { return offset >= edit.start && offset < edit.end })
+	})
+
+	return walker.importEdits(file, src)
+}
+
+// declarationEdits returns the edits declaring a new error at the end of its generated file, along with the imports it
+// needs there. Running grr gen afterwards puts it in its place. There are none when the file isn't part of the package
+func (walker *grrWalker) declarationEdits(layout *outputLayout, genErr GeneratedError) ([]TextEdit, error) {
+	path := layout.outputFile(genErr)
+	file := syntaxOf(walker.pkg, path)
+
+	if file == nil {
+		return nil, nil
+	}
+
+	pkgName, pkgPath := errorsPackage(walker.pkg, walker.cfg)
+
+	code, err := GenerateErrorFile(walker.templates, pkgName, pkgPath, walker.imports.ToSlice(), map[string]GeneratedError{genErr.Name: genErr})
+
+	if err != nil {
+		return nil, grr.Errorf("GenerateErrorFile: failed to generate error file").AddError(err)
+	}
+
+	// the declaration is everything the rendered file has after its imports
+	fset := token.NewFileSet()
+	rendered, err := parser.ParseFile(fset, path, code, parser.ImportsOnly)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToParse: failed to parse the generated %s", genErr.Name).AddError(err)
+	}
+
+	declStart := fset.Position(rendered.Name.End()).Offset
+
+	if len(rendered.Decls) > 0 {
+		declStart = fset.Position(rendered.Decls[len(rendered.Decls)-1].End()).Offset
+	}
+
+	tokFile := walker.fset.File(file.Pos())
+	imported := map[string]bool{}
+
+	for _, spec := range file.Imports {
+		imported[spec.Path.Value] = true
+	}
+
+	edits := []textEdit{}
+
+	for _, spec := range rendered.Imports {
+		if !imported[spec.Path.Value] {
+			edits = append(edits, addImportEdit(file, tokFile, spec.Path.Value))
+		}
+	}
+
+	edits = append(edits, textEdit{
+		start: tokFile.Size(),
+		end:   tokFile.Size(),
+		text:  "\n" + strings.TrimLeft(string(code[declStart:]), "\n"),
+	})
+
+	return exportEdits(path, edits), nil
+}
+
+// exportEdits returns the edits of the file at path as TextEdits
+func exportEdits(path string, edits []textEdit) []TextEdit {
+	exported := []TextEdit{}
+
+	for _, edit := range edits {
+		exported = append(exported, TextEdit{File: path, Start: edit.start, End: edit.end, NewText: edit.text})
+	}
+
+	return exported
+}
@@ -0,0 +1,240 @@
+package gen
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/grr"
+	"golang.org/x/tools/go/packages"
+)
+
+// Kinds of problems reported by CheckEntry
+const (
+	ProblemUnconverted = "unconverted"
+	ProblemConflict    = "conflict"
+	ProblemInvalid     = "invalid"
+	ProblemMissing     = "missing"
+	ProblemStale       = "stale"
+	ProblemModified    = "modified"
+	ProblemLoad        = "load"
+)
+
+// CheckProblem is something that keeps generation from being complete and up to date
+type CheckProblem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func (p CheckProblem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", p.File, p.Line, p.Column, p.Kind, p.Message)
+}
+
+func newCheckProblem(pos token.Position, kind string, format string, args ...any) CheckProblem {
+	return CheckProblem{
+		File:    pos.Filename,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// missingErrorPattern matches type errors caused by call sites using generated errors that aren't in grr.gen.go
+func missingErrorPattern(cfg *config.Config) *regexp.Regexp {
+	return regexp.MustCompile(`undefined: (?:\w+\.)?(?:New)?(` + regexp.QuoteMeta(cfg.ErrorPrefix) + `\w+)`)
+}
+
+// CheckEntry verifies that generation is complete and up to date for every package in a directory, without writing anything.
+// It reports unconverted grr.Errorf call sites, generated errors that are missing, stale or edited by hand, and conflicting errors.
+// Problems are sorted by position
+func CheckEntry(directory string, cfg *config.Config) ([]CheckProblem, error) {
+	tmpls, err := LoadTemplates(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := loadPackages(directory, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// errors referenced only from tests aren't stale
+	testPkgs, err := loadPackagesWithTests(directory, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []CheckProblem{}
+	used := usedObjects(testPkgs, cfg)
+	missing := missingErrorPattern(cfg)
+
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			for _, pkgErr := range pkg.Errors {
+				kind := ProblemLoad
+
+				if missing.MatchString(pkgErr.Msg) {
+					kind = ProblemMissing
+				}
+
+				problems = append(problems, newCheckProblem(parsePosition(pkgErr.Pos), kind, "%s", pkgErr.Msg))
+			}
+
+			continue
+		}
+
+		pkgWalker, _, err := walkPackage(pkg, cfg, tmpls, io.Discard)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if pkgWalker == nil {
+			continue
+		}
+
+		for _, genErr := range pkgWalker.generatedErrors {
+			problems = append(problems, newCheckProblem(genErr.Pos, ProblemUnconverted, "grr.Errorf call for %s has not been generated", genErr.Name))
+		}
+
+		for _, reused := range pkgWalker.reusedCalls {
+			problems = append(problems, newCheckProblem(reused.Pos, ProblemUnconverted, "grr.Errorf call for %s has not been converted to the existing error", reused.Name))
+		}
+
+		for _, diagnostic := range pkgWalker.diagnostics {
+			pos := token.Position{Filename: diagnostic.File, Line: diagnostic.Line, Column: diagnostic.Column}
+
+			switch {
+			case diagnostic.Severity == SeverityInfo:
+				continue
+			case diagnostic.Code == "Conflict":
+				problems = append(problems, newCheckProblem(pos, ProblemConflict, "%s: %s", diagnostic.Code, diagnostic.Message))
+			default:
+				problems = append(problems, newCheckProblem(pos, ProblemInvalid, "%s: %s", diagnostic.Code, diagnostic.Message))
+			}
+		}
+
+		_, errorsPath := errorsPackage(pkg, cfg)
+
+		for name, prevErr := range pkgWalker.prevErrors {
+			if !used.Has(errorsPath+"."+name) && !used.Has(errorsPath+".New"+name) {
+				problems = append(problems, newCheckProblem(prevErr.Pos, ProblemStale, "%s is no longer used", name))
+			}
+
+			if modified, err := isModified(cfg, tmpls, pkg, prevErr); err != nil {
+				return nil, err
+			} else if modified {
+				problems = append(problems, newCheckProblem(prevErr.Pos, ProblemModified, "%s differs from its generated code", name))
+			}
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b CheckProblem) int {
+		if a.File != b.File {
+			return strings.Compare(a.File, b.File)
+		}
+
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+
+		if a.Column != b.Column {
+			return a.Column - b.Column
+		}
+
+		return strings.Compare(a.Kind, b.Kind)
+	})
+
+	return problems, nil
+}
+
+// isModified reports whether the code of a previous error was edited since it was generated, or differs from what the templates generate for it
+func isModified(cfg *config.Config, tmpls *Templates, pkg *packages.Package, prevErr GeneratedError) (bool, error) {
+	// the message is read back from the code, so an edited message is only told apart by the checksum
+	if prevErr.Checksum != "" && codeChecksum(prevErr.GeneratedCode) != prevErr.Checksum {
+		return true, nil
+	}
+
+	name := strings.TrimPrefix(prevErr.Name, cfg.ErrorPrefix)
+	pkgName, pkgPath := errorsPackage(pkg, cfg)
+
+	code, _, err := renderErrorCode(tmpls, StructTemplateData{
+		Name:    name,
+		ID:      name,
+		ErrName: prevErr.Name,
+		Vars:    prevErr.Args,
+		Message: prevErr.Msg,
+		PkgName: pkgName,
+		PkgPath: pkgPath,
+
+		DefaultTraits: prevErr.DefaultTraits,
+		DefaultOp:     prevErr.DefaultOp,
+		ExportFields:  cfg.ExportFields,
+	})
+
+	if err != nil {
+		return false, grr.Errorf("FailedToExecuteTemplate: failed to render %s", prevErr.Name).AddError(err)
+	}
+
+	generated, err := format.Source([]byte("package p\n" + code))
+
+	if err != nil {
+		return false, grr.Errorf("FailedToFormat: failed to format generated %s", prevErr.Name).AddError(err)
+	}
+
+	existing, err := format.Source([]byte("package p\n" + prevErr.GeneratedCode))
+
+	if err != nil {
+		// code that doesn't even parse has certainly been modified
+		return true, nil
+	}
+
+	return string(generated) != string(existing), nil
+}
+
+// codeChecksum returns the checksum of the code of an error, which doesn't depend on how the code is formatted
+func codeChecksum(code string) string {
+	src := []byte("package p\n" + code)
+
+	if formatted, err := format.Source(src); err == nil {
+		src = formatted
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(src))
+}
+
+// parsePosition parses the file:line:col positions used by packages.Error
+func parsePosition(pos string) token.Position {
+	parts := strings.Split(pos, ":")
+
+	if len(parts) < 3 {
+		return token.Position{Filename: pos}
+	}
+
+	line, lineErr := strconv.Atoi(parts[len(parts)-2])
+	column, columnErr := strconv.Atoi(parts[len(parts)-1])
+
+	if lineErr != nil || columnErr != nil {
+		return token.Position{Filename: pos}
+	}
+
+	return token.Position{
+		Filename: strings.Join(parts[:len(parts)-2], ":"),
+		Line:     line,
+		Column:   column,
+	}
+}
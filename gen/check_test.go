@@ -0,0 +1,35 @@
+package gen
+
+import (
+	"go/token"
+	"testing"
+)
+
+func TestCodeChecksum(t *testing.T) {
+	generated := "\nvar ErrTimeout = grr.SentinelWithID(\"Timeout\", \"timed out\")\n"
+
+	if codeChecksum(generated) != codeChecksum("var  ErrTimeout =   grr.SentinelWithID(\"Timeout\", \"timed out\")") {
+		t.Errorf("the checksum depends on formatting")
+	}
+
+	if codeChecksum(generated) == codeChecksum("var ErrTimeout = grr.SentinelWithID(\"Timeout\", \"timed OUT\")") {
+		t.Errorf("the checksum doesn't change with the message")
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		pos  string
+		want token.Position
+	}{
+		{pos: "/a/b.go:3:14", want: token.Position{Filename: "/a/b.go", Line: 3, Column: 14}},
+		{pos: `C:\a\b.go:3:14`, want: token.Position{Filename: `C:\a\b.go`, Line: 3, Column: 14}},
+		{pos: "-", want: token.Position{Filename: "-"}},
+	}
+
+	for _, test := range tests {
+		if got := parsePosition(test.pos); got != test.want {
+			t.Errorf("parsePosition(%q) = %v, want %v", test.pos, got, test.want)
+		}
+	}
+}
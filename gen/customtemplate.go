@@ -0,0 +1,371 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/scanner"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/grr"
+)
+
+// templateFuncs are the funcs available to every template, embedded or custom
+var templateFuncs = template.FuncMap{
+	"notlast": func(index int, len int) bool {
+		return index+1 != len
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	// FileNotFound => fileNotFound
+	"camel": func(s string) string {
+		return untitle(strings.Join(titleWords(s), ""))
+	},
+	// fileNotFound => FileNotFound
+	"pascal": func(s string) string {
+		return strings.Join(titleWords(s), "")
+	},
+	// FileNotFound => file_not_found
+	"snake": func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "_"))
+	},
+	// FileNotFound => file-not-found
+	"kebab": func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "-"))
+	},
+	// FileNotFound => FILE_NOT_FOUND
+	"screaming": func(s string) string {
+		return strings.ToUpper(strings.Join(splitWords(s), "_"))
+	},
+	"title":   title,
+	"untitle": untitle,
+	// a Go string literal, e.g. for a message in a struct tag or a map
+	"quote": strconv.Quote,
+	// a Go raw string literal, falling back to an interpreted one when s contains a backquote
+	"backquote": func(s string) string {
+		if strconv.CanBackquote(s) {
+			return "`" + s + "`"
+		}
+
+		return strconv.Quote(s)
+	},
+	"join":       strings.Join,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+	"hasPrefix":  strings.HasPrefix,
+	"replace":    strings.ReplaceAll,
+}
+
+// Templates are the templates errors and generated files are rendered with
+type Templates struct {
+	// Executed with StructTemplateData for errors with arguments
+	Struct *template.Template
+	// Executed with StructTemplateData for errors without arguments
+	Sentinel *template.Template
+	// Executed with HeaderTemplateData for the generated file
+	File *template.Template
+}
+
+// rendersDefaults reports whether the struct template renders the defaults of errors, so that they can be lifted from call sites.
+// It looks for the DefaultTraits and DefaultOp fields in the actions of the template and those it defines, so that text and comments mentioning them don't count
+func (tmpls *Templates) rendersDefaults() bool {
+	fields := map[string]bool{}
+
+	for _, tmpl := range tmpls.Struct.Templates() {
+		if tmpl.Tree != nil {
+			templateFields(tmpl.Tree.Root, fields)
+		}
+	}
+
+	return fields["DefaultTraits"] && fields["DefaultOp"]
+}
+
+// templateFields adds the names of the fields node refers to, e.g. DefaultOp for .DefaultOp, $.DefaultOp or $d := .DefaultOp
+func templateFields(node parse.Node, fields map[string]bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+
+		for _, child := range node.Nodes {
+			templateFields(child, fields)
+		}
+	case *parse.ActionNode:
+		templateFields(node.Pipe, fields)
+	case *parse.IfNode:
+		templateFields(&node.BranchNode, fields)
+	case *parse.RangeNode:
+		templateFields(&node.BranchNode, fields)
+	case *parse.WithNode:
+		templateFields(&node.BranchNode, fields)
+	case *parse.BranchNode:
+		templateFields(node.Pipe, fields)
+		templateFields(node.List, fields)
+		templateFields(node.ElseList, fields)
+	case *parse.TemplateNode:
+		templateFields(node.Pipe, fields)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+
+		for _, cmd := range node.Cmds {
+			templateFields(cmd, fields)
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			templateFields(arg, fields)
+		}
+	case *parse.ChainNode:
+		templateFields(node.Node, fields)
+
+		for _, field := range node.Field {
+			fields[field] = true
+		}
+	case *parse.FieldNode:
+		for _, field := range node.Ident {
+			fields[field] = true
+		}
+	case *parse.VariableNode:
+		for _, field := range node.Ident[1:] {
+			fields[field] = true
+		}
+	}
+}
+
+// DefaultTemplates returns the embedded templates
+func DefaultTemplates() *Templates {
+	return &Templates{
+		Struct:   errorStructTemplate,
+		Sentinel: errorSentinelTemplate,
+		File:     errorFileTemplate,
+	}
+}
+
+// LoadTemplates returns the templates of a config, the embedded ones standing in for those it doesn't override.
+// Custom templates are validated by rendering a sample error, so that a broken template is reported once here
+// rather than at every call site
+func LoadTemplates(cfg *config.Config) (*Templates, error) {
+	tmpls := DefaultTemplates()
+	files := cfg.Templates
+
+	if files.Struct == "" && files.Sentinel == "" && files.File == "" {
+		return tmpls, nil
+	}
+
+	if files.Version != 0 && files.Version != TemplateDataVersion {
+		return nil, grr.Errorf("UnsupportedTemplateVersion: templates were written for data version %d, but this grr generates version %d", files.Version, TemplateDataVersion)
+	}
+
+	for _, override := range []struct {
+		path string
+		tmpl **template.Template
+	}{
+		{files.Struct, &tmpls.Struct},
+		{files.Sentinel, &tmpls.Sentinel},
+		{files.File, &tmpls.File},
+	} {
+		if override.path == "" {
+			continue
+		}
+
+		tmpl, err := parseTemplate(cfg.Path(override.path))
+
+		if err != nil {
+			return nil, err
+		}
+
+		*override.tmpl = tmpl
+	}
+
+	if err := tmpls.validate(cfg); err != nil {
+		return nil, grr.Errorf("InvalidTemplates: the custom templates don't generate valid code").AddError(err)
+	}
+
+	return tmpls, nil
+}
+
+// parseTemplate parses the template file at path, named after the file so that errors point at it
+func parseTemplate(path string) (*template.Template, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToReadTemplate: failed to read template %s", path).AddError(err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(content))
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToParseTemplate: failed to parse template %s", path).AddError(err)
+	}
+
+	return tmpl, nil
+}
+
+// validate renders a sample error with and without arguments along with their file
+func (tmpls *Templates) validate(cfg *config.Config) error {
+	sample := StructTemplateData{
+		Name:    "FileNotFound",
+		ID:      "FileNotFound",
+		ErrName: cfg.ErrorPrefix + "FileNotFound",
+		Vars:    []GrrGenErrorField{{Expr: "path", Name: "path", Type: "string"}},
+		Message: "file %s not found",
+		PkgName: "sample",
+		PkgPath: "example.com/sample",
+		Traits:  []TemplateTrait{{Trait: "grr.TrCode", Value: "404"}},
+
+		DefaultTraits: []TemplateTrait{{Trait: "grr.TrCode", Value: "404"}},
+		DefaultOp:     "sample.Open",
+		ExportFields:  cfg.ExportFields,
+	}
+
+	sentinel := sample
+	sentinel.Name = "Timeout"
+	sentinel.ID = "Timeout"
+	sentinel.ErrName = cfg.ErrorPrefix + "Timeout"
+	sentinel.Vars = nil
+	sentinel.Message = "timed out"
+
+	generated := map[string]GeneratedError{}
+
+	for _, data := range []StructTemplateData{sample, sentinel} {
+		code, isSentinel, err := renderErrorCode(tmpls, data)
+
+		if err != nil {
+			return err
+		}
+
+		generated[data.ErrName] = GeneratedError{
+			Name:          data.ErrName,
+			Args:          data.Vars,
+			Msg:           data.Message,
+			IsSentinel:    isSentinel,
+			GeneratedCode: code,
+		}
+	}
+
+	_, err := GenerateErrorFile(tmpls, sample.PkgName, sample.PkgPath, GenDefaultImports(), generated)
+
+	return err
+}
+
+// formatGenerated formats the output of the template called name.
+// If it isn't valid Go, the error quotes the offending lines so that the template can be fixed without digging through its output
+func formatGenerated(name string, src []byte) ([]byte, error) {
+	formatted, err := format.Source(src)
+
+	if err == nil {
+		return formatted, nil
+	}
+
+	var errList scanner.ErrorList
+
+	if !errors.As(err, &errList) || len(errList) == 0 {
+		return nil, grr.Errorf("InvalidGeneratedCode: %s generated invalid Go code", name).AddError(err)
+	}
+
+	line := errList[0].Pos.Line
+	lines := strings.Split(string(src), "\n")
+
+	var excerpt strings.Builder
+
+	for idx := max(line-3, 0); idx < min(line+2, len(lines)); idx++ {
+		marker := " "
+
+		if idx+1 == line {
+			marker = ">"
+		}
+
+		fmt.Fprintf(&excerpt, "%s %4d | %s\n", marker, idx+1, lines[idx])
+	}
+
+	return nil, grr.Errorf("InvalidGeneratedCode: %s generated invalid Go code at line %d: %s\n%s", name, line, errList[0].Msg, excerpt.String())
+}
+
+// splitWords splits an identifier or phrase into words at case changes and separators, e.g. HTTPRequestFailed => HTTP Request Failed
+func splitWords(s string) []string {
+	words := []string{}
+	runes := []rune(s)
+	start := -1
+
+	for idx, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:idx]))
+			}
+
+			start = -1
+			continue
+		}
+
+		if start < 0 {
+			start = idx
+			continue
+		}
+
+		prev := runes[idx-1]
+		nextIsLower := idx+1 < len(runes) && unicode.IsLower(runes[idx+1])
+
+		// a new word starts at fooBar, and at the last capital of an acronym like HTTPRequest
+		if unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower)) {
+			words = append(words, string(runes[start:idx]))
+			start = idx
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+// titleWords splits s into words and capitalizes the first letter of each
+func titleWords(s string) []string {
+	words := splitWords(s)
+
+	for idx, word := range words {
+		words[idx] = title(word)
+	}
+
+	return words
+}
+
+// title capitalizes the first letter of s
+func title(s string) string {
+	runes := []rune(s)
+
+	if len(runes) == 0 {
+		return s
+	}
+
+	runes[0] = unicode.ToUpper(runes[0])
+
+	return string(runes)
+}
+
+// untitle lowercases the first letter of s, or the whole leading acronym, e.g. HTTPRequest => httpRequest
+func untitle(s string) string {
+	runes := []rune(s)
+	idx := 0
+
+	for idx < len(runes) && unicode.IsUpper(runes[idx]) {
+		// keep the capital that starts the next word
+		if idx > 0 && idx+1 < len(runes) && unicode.IsLower(runes[idx+1]) {
+			break
+		}
+
+		runes[idx] = unicode.ToLower(runes[idx])
+		idx++
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,136 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/grr"
+)
+
+func TestRendersDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   bool
+	}{
+		{name: "fields", source: "{{ .DefaultOp }}{{ range .DefaultTraits }}{{ .Trait }}{{ end }}", want: true},
+		{name: "root variable", source: "{{ range .Vars }}{{ $.DefaultOp }}{{ $.DefaultTraits }}{{ end }}", want: true},
+		{name: "declared", source: "{{ $op := .DefaultOp }}{{ $traits := .DefaultTraits }}{{ $op }}{{ $traits }}", want: true},
+		{name: "defined template", source: `{{ define "defaults" }}{{ .DefaultOp }}{{ .DefaultTraits }}{{ end }}{{ template "defaults" . }}`, want: true},
+		{name: "condition", source: "{{ if and .DefaultOp .DefaultTraits }}defaults{{ end }}", want: true},
+		{name: "op only", source: "{{ .DefaultOp }}", want: false},
+		{name: "text", source: "// set .DefaultOp and .DefaultTraits by hand\n{{ .Name }}", want: false},
+		{name: "comment", source: "{{/* .DefaultOp .DefaultTraits */}}{{ .Name }}", want: false},
+		{name: "string", source: `{{ print ".DefaultOp .DefaultTraits" }}`, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpls := DefaultTemplates()
+			tmpls.Struct = template.Must(template.New("struct").Funcs(templateFuncs).Parse(test.source))
+
+			if got := tmpls.rendersDefaults(); got != test.want {
+				t.Errorf("rendersDefaults() = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if !DefaultTemplates().rendersDefaults() {
+		t.Errorf("the embedded struct template doesn't render the defaults")
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	sentinel := `var {{ .ErrName }} = grr.SentinelWithID("{{ .ID }}", "{{ .Message }}")`
+
+	tests := []struct {
+		name    string
+		content string
+		version int
+		wantID  string
+	}{
+		{name: "valid", content: sentinel},
+		{name: "current version", content: sentinel, version: TemplateDataVersion},
+		{name: "other version", content: sentinel, version: TemplateDataVersion + 1, wantID: "UnsupportedTemplateVersion"},
+		{name: "unparsable", content: "var {{ .ErrName = 1", wantID: "FailedToParseTemplate"},
+		{name: "missing field", content: "var {{ .Missing }} = 1", wantID: "InvalidTemplates"},
+		{name: "invalid code", content: "var {{ .ErrName }} = ", wantID: "InvalidTemplates"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Root = t.TempDir()
+			cfg.Templates = config.TemplateFiles{Sentinel: "sentinel.tmpl", Version: test.version}
+
+			if err := os.WriteFile(filepath.Join(cfg.Root, "sentinel.tmpl"), []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			tmpls, err := LoadTemplates(cfg)
+
+			if grr.ID(err) != test.wantID {
+				t.Fatalf("got error %v, want %q", err, test.wantID)
+			}
+
+			if err == nil && (tmpls.Sentinel.Name() != "sentinel.tmpl" || tmpls.Struct != DefaultTemplates().Struct) {
+				t.Errorf("got the templates %s and %s, want the custom sentinel one and the embedded struct one", tmpls.Sentinel.Name(), tmpls.Struct.Name())
+			}
+		})
+	}
+
+	cfg := config.Default()
+	cfg.Root = t.TempDir()
+	cfg.Templates.File = "missing.tmpl"
+
+	if _, err := LoadTemplates(cfg); grr.ID(err) != "FailedToReadTemplate" {
+		t.Errorf("got error %v for a missing template, want FailedToReadTemplate", err)
+	}
+}
+
+const customTemplateMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(p string) error {
+	return grr.Errorf("NotFound: %s was not found", p).AddTrait(grr.TrCode, 404)
+}
+`
+
+// Call sites keep their defaults when the custom struct template doesn't render them
+func TestCustomTemplateDefaults(t *testing.T) {
+	embedded, err := os.ReadFile("errorStruct.tmpl")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the embedded template without the defaults in its constructor
+	withoutDefaults := regexp.MustCompile(`(?s)\s*\{\{- range \.DefaultTraits }}.*?\{\{- end }}|\s*\{\{- if \.DefaultOp }}.*?\{\{- end }}`).ReplaceAllString(string(embedded), "")
+
+	dir := writeModule(t, map[string]string{
+		"main.go":     customTemplateMain,
+		"struct.tmpl": "// Custom\n" + withoutDefaults,
+	})
+
+	cfg := config.Default()
+	cfg.Root = dir
+	cfg.Templates.Struct = "struct.tmpl"
+
+	assertConverted(t, generateWith(t, dir, cfg))
+
+	if generated := readFile(t, dir, "grr.gen.go"); !strings.Contains(generated, "// Custom\n") || strings.Contains(generated, "404") {
+		t.Errorf("grr.gen.go isn't rendered with the custom template:\n%s", generated)
+	}
+
+	if main := readFile(t, dir, "main.go"); !strings.Contains(main, `NewErrNotFound(p).AddOp("main.A").AddTrait(grr.TrCode, 404)`) {
+		t.Errorf("main.go doesn't keep the defaults at the call site:\n%s", main)
+	}
+}
@@ -0,0 +1,255 @@
+package gen
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"slices"
+
+	"github.com/jackHedaya/grr/config"
+)
+
+// chainTraits returns the traits a chain of calls adds, as written at the call site
+func chainTraits(chain []*ast.CallExpr) []TemplateTrait {
+	traits := []TemplateTrait{}
+
+	for _, callExpr := range chain {
+		if selExpr := callExpr.Fun.(*ast.SelectorExpr); selExpr.Sel.Name == "AddTrait" && len(callExpr.Args) == 2 {
+			traits = append(traits, TemplateTrait{Trait: types.ExprString(callExpr.Args[0]), Value: types.ExprString(callExpr.Args[1])})
+		}
+	}
+
+	return traits
+}
+
+// liftDefaults turns the AddTrait and AddOp calls with constant arguments that directly follow a grr.Errorf call into defaults
+// of the generated error, returning the lifted calls along with the defaults. Only the leading calls are lifted,
+// so that whatever the rest of the chain does still happens after them
+func (walker *grrWalker) liftDefaults(chain []*ast.CallExpr) ([]*ast.CallExpr, []TemplateTrait, string) {
+	lifted := []*ast.CallExpr{}
+	traits := []TemplateTrait{}
+	op := ""
+
+	for _, callExpr := range chain {
+		selExpr := callExpr.Fun.(*ast.SelectorExpr)
+
+		if selExpr.Sel.Name == "AddOp" && len(callExpr.Args) == 1 {
+			value, ok := constantString(walker.info, callExpr.Args[0])
+
+			if !ok {
+				break
+			}
+
+			op = value
+
+		} else if selExpr.Sel.Name == "AddTrait" && len(callExpr.Args) == 2 {
+			trait, ok := walker.traitExpr(callExpr.Args[0])
+
+			if !ok {
+				break
+			}
+
+			value, ok := constantLiteral(walker.info, callExpr.Args[1])
+
+			if !ok {
+				break
+			}
+
+			// a trait added twice keeps the last value
+			for idx, prev := range traits {
+				if prev.Trait == trait {
+					traits = append(traits[:idx], traits[idx+1:]...)
+					break
+				}
+			}
+
+			traits = append(traits, TemplateTrait{Trait: trait, Value: value})
+
+		} else {
+			break
+		}
+
+		lifted = append(lifted, callExpr)
+	}
+
+	return lifted, traits, op
+}
+
+// sharedDefaults returns whether the call sites of each error in files lift the same defaults from their chains, by error name.
+// The constructor starts every error it builds with the defaults, so they are only lifted when no call site would get
+// defaults it doesn't add itself. Whether they are lifted then doesn't depend on the order the call sites are walked in
+func (walker *grrWalker) sharedDefaults(files []*ast.File) map[string]bool {
+	type defaults struct {
+		traits []TemplateTrait
+		op     string
+	}
+
+	first := map[string]defaults{}
+	shared := map[string]bool{}
+
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			walker.recordChain(n)
+
+			grrNode, ok := getGrrNode(walker.cfg, walker.fset, walker.info, n)
+
+			// errors without arguments are sentinels, which have no defaults
+			if !ok || len(grrNode.CallExpr.Args) < 2 {
+				return true
+			}
+
+			format, ok := constantString(walker.info, grrNode.CallExpr.Args[0])
+
+			if !ok {
+				return true
+			}
+
+			name, ok := walker.errorName(format)
+
+			if !ok {
+				return true
+			}
+
+			_, traits, op := walker.liftDefaults(walker.chains[grrNode.CallExpr])
+
+			prev, seen := first[name]
+
+			if !seen {
+				first[name] = defaults{traits, op}
+				shared[name] = true
+			} else if !slices.Equal(prev.traits, traits) || prev.op != op {
+				shared[name] = false
+			}
+
+			return true
+		})
+	}
+
+	return shared
+}
+
+// errorName returns the name of the error a format string generates, e.g. ErrFileNotFound for "FileNotFound: ..."
+func (walker *grrWalker) errorName(format string) (string, bool) {
+	matches := walker.cfg.NameRegexp().FindStringSubmatch(escapeString(format))
+
+	if len(matches) < 3 {
+		return "", false
+	}
+
+	return walker.cfg.ErrorPrefix + matches[1], true
+}
+
+// traitExpr returns how the generated file refers to the package level variable expr names, e.g. grr.TrCode.
+// Variables of the package itself can't be referred to from the errors package of the subpackage layout
+func (walker *grrWalker) traitExpr(expr ast.Expr) (string, bool) {
+	var ident *ast.Ident
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		ident = e
+
+	case *ast.SelectorExpr:
+		ident = e.Sel
+
+	default:
+		return "", false
+	}
+
+	obj, ok := walker.info.Uses[ident].(*types.Var)
+
+	if !ok || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+		return "", false
+	}
+
+	if obj.Pkg() != walker.pkg.Types {
+		walker.imports.Add(obj.Pkg().Path())
+
+		return obj.Pkg().Name() + "." + obj.Name(), true
+	}
+
+	if _, ok := expr.(*ast.Ident); !ok || walker.cfg.Layout == config.LayoutSubpackage {
+		return "", false
+	}
+
+	return obj.Name(), true
+}
+
+// constantLiteral returns a literal with the value and type of a constant expression, e.g. int64(5) for a typed constant.
+// Only booleans, strings and integers of basic types are supported, since other constants don't have an exact literal
+func constantLiteral(info *types.Info, expr ast.Expr) (string, bool) {
+	tv, ok := info.Types[expr]
+
+	if !ok || tv.Value == nil {
+		return "", false
+	}
+
+	basic, ok := types.Default(tv.Type).(*types.Basic)
+
+	if !ok {
+		return "", false
+	}
+
+	literal := tv.Value.ExactString()
+
+	switch {
+	case tv.Value.Kind() == constant.Bool && basic.Kind() == types.Bool:
+		return literal, true
+
+	case tv.Value.Kind() == constant.String && basic.Kind() == types.String:
+		return literal, true
+
+	case tv.Value.Kind() == constant.Int && basic.Kind() == types.Int:
+		return literal, true
+
+	case tv.Value.Kind() == constant.Int && basic.Info()&types.IsInteger != 0:
+		return basic.Name() + "(" + literal + ")", true
+	}
+
+	return "", false
+}
+
+// chainAddsOp reports whether a chain of calls sets the op
+func chainAddsOp(chain []*ast.CallExpr) bool {
+	for _, callExpr := range chain {
+		if callExpr.Fun.(*ast.SelectorExpr).Sel.Name == "AddOp" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enclosingOp returns the op of the function declaring the call, e.g. pkg.Type.Method or pkg.Func,
+// or "" when the call isn't inside a function, e.g. in the initializer of a package level variable
+func (walker *grrWalker) enclosingOp(callExpr *ast.CallExpr) string {
+	for _, decl := range walker.file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+
+		if !ok || callExpr.Pos() < funcDecl.Pos() || callExpr.End() > funcDecl.End() {
+			continue
+		}
+
+		if funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 {
+			return walker.pkg.Name + "." + funcDecl.Name.Name
+		}
+
+		recv := funcDecl.Recv.List[0].Type
+
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+
+		// the type parameters of generic receivers aren't part of the type name
+		switch r := recv.(type) {
+		case *ast.IndexExpr:
+			recv = r.X
+
+		case *ast.IndexListExpr:
+			recv = r.X
+		}
+
+		return walker.pkg.Name + "." + types.ExprString(recv) + "." + funcDecl.Name.Name
+	}
+
+	return ""
+}
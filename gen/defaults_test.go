@@ -0,0 +1,172 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/config"
+)
+
+const defaultsMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(p string) error {
+	return grr.Errorf("NotFound: %s was not found", p).AddTrait(grr.TrCode, "NotFound").AddOp("custom.op")
+}
+`
+
+const defaultsOther = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func B(p string) error {
+	return grr.Errorf("NotFound: %s was not found", p)
+}
+
+func C(n int) error {
+	return grr.Errorf("Limit: %d reached", n).AddTrait(grr.TrCode, "Limit")
+}
+
+func D(n int) error {
+	return grr.Errorf("Limit: %d reached", n).AddTrait(grr.TrCode, "Limit")
+}
+`
+
+// The defaults of an error are only lifted when every call site adds them, whichever file is walked first
+func TestDefaultsShared(t *testing.T) {
+	for _, names := range [][2]string{{"a.go", "b.go"}, {"b.go", "a.go"}} {
+		t.Run(names[0]+" first", func(t *testing.T) {
+			dir := writeModule(t, map[string]string{names[0]: defaultsMain, names[1]: defaultsOther})
+
+			for _, diagnostic := range generate(t, dir) {
+				if diagnostic.Severity != SeverityInfo {
+					t.Errorf("unexpected diagnostic %s", diagnostic)
+				}
+			}
+
+			main := readFile(t, dir, names[0])
+			other := readFile(t, dir, names[1])
+			generated := readFile(t, dir, "grr.gen.go")
+
+			for _, want := range []string{
+				`return NewErrNotFound(p).AddTrait(grr.TrCode, "NotFound").AddOp("custom.op")`,
+			} {
+				if !strings.Contains(main, want) {
+					t.Errorf("%s doesn't contain %s:\n%s", names[0], want, main)
+				}
+			}
+
+			for _, want := range []string{
+				`return NewErrNotFound(p).AddOp("main.B")`,
+				`return NewErrLimit(n).AddOp("main.C")`,
+				`return NewErrLimit(n).AddOp("main.D")`,
+			} {
+				if !strings.Contains(other, want) {
+					t.Errorf("%s doesn't contain %s:\n%s", names[1], want, other)
+				}
+			}
+
+			// only the defaults every call site of ErrLimit adds are lifted
+			if strings.Contains(generated, "custom.op") || strings.Count(generated, "grr.TrCode:") != 1 || !strings.Contains(generated, `grr.TrCode: "Limit"`) {
+				t.Errorf("unexpected defaults in grr.gen.go:\n%s", generated)
+			}
+		})
+	}
+}
+
+// A call site can't reuse an error whose constructor adds defaults it doesn't add itself
+func TestDefaultsConflict(t *testing.T) {
+	dir := writeModule(t, map[string]string{"a.go": defaultsOther})
+
+	generate(t, dir)
+
+	later := "package main\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nfunc E(n int) error {\n\treturn grr.Errorf(\"Limit: %d reached\", n)\n}\n"
+	writeFiles(t, dir, map[string]string{"e.go": later})
+
+	diagnostics := generate(t, dir)
+
+	if len(diagnostics) != 1 || diagnostics[0].Code != "Conflict" {
+		t.Fatalf("diagnostics = %v, want a conflict", diagnostics)
+	}
+
+	if got := readFile(t, dir, "e.go"); got != later {
+		t.Errorf("e.go was rewritten:\n%s", got)
+	}
+}
+
+const autoOpStore = `package store
+
+import "github.com/jackHedaya/grr/grr"
+
+type DB struct{}
+
+type Cache[K comparable, V any] struct{}
+
+func Open(path string) error {
+	return grr.Errorf("OpenFailed: failed to open %s", path)
+}
+
+func (db *DB) Get(key string) error {
+	return grr.Errorf("NotFound: %s was not found", key)
+}
+
+func (c Cache[K, V]) Load(key K) error {
+	return grr.Errorf("Missing: %v is missing", key)
+}
+
+func Retry(n int) func() error {
+	return func() error {
+		return grr.Errorf("RetryFailed: gave up after %d tries", n)
+	}
+}
+
+func Explicit(path string) error {
+	return grr.Errorf("ReadFailed: failed to read %s", path).AddOp("store.read")
+}
+`
+
+// Call sites without an op get the one of their enclosing function, e.g. store.DB.Get for a method and store.Cache.Load for a generic one
+func TestAutoOp(t *testing.T) {
+	dir := writeModule(t, map[string]string{"store.go": autoOpStore})
+
+	assertConverted(t, generate(t, dir))
+
+	store := readFile(t, dir, "store.go")
+
+	for _, want := range []string{
+		`return NewErrOpenFailed(path).AddOp("store.Open")`,
+		`return NewErrNotFound(key).AddOp("store.DB.Get")`,
+		`return NewErrMissing(key).AddOp("store.Cache.Load")`,
+		`return NewErrRetryFailed(n).AddOp("store.Retry")`,
+		// the explicit op is lifted into the constructor rather than replaced
+		"return NewErrReadFailed(path)\n",
+	} {
+		if !strings.Contains(store, want) {
+			t.Errorf("store.go doesn't contain %s:\n%s", want, store)
+		}
+	}
+
+	generated := readFile(t, dir, "grr.gen.go")
+
+	if !strings.Contains(generated, `op:      "store.read",`) {
+		t.Errorf("grr.gen.go doesn't keep the explicit op:\n%s", generated)
+	}
+
+	// the type parameter of the receiver isn't in scope in grr.gen.go
+	if !strings.Contains(generated, "func NewErrMissing(key any) *ErrMissing {") {
+		t.Errorf("grr.gen.go doesn't take the type parameter argument as any:\n%s", generated)
+	}
+
+	dir = writeModule(t, map[string]string{"store.go": autoOpStore})
+	cfg := layoutConfig(dir, config.LayoutPackage)
+	cfg.AutoOp = false
+
+	assertConverted(t, generateWith(t, dir, cfg))
+
+	if store := readFile(t, dir, "store.go"); strings.Contains(store, ".AddOp(") {
+		t.Errorf("store.go adds ops with autoOp off:\n%s", store)
+	}
+}
@@ -0,0 +1,95 @@
+package gen
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"github.com/jackHedaya/grr/grr"
+	"github.com/jackHedaya/grr/utils"
+)
+
+// Severities of diagnostics
+const (
+	// The call site or package could not be processed
+	SeverityError = "error"
+	// The call site is left as is, but that may be intended
+	SeverityWarning = "warning"
+	// What was done with a call site
+	SeverityInfo = "info"
+)
+
+// Diagnostic is something the generator found at a position. Diagnostics are collected across the whole run,
+// so that a call site that can't be converted doesn't keep the others from being processed
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	// The ID of the problem, e.g. Conflict or InvalidFormat
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// How to fix the problem, when there is a usual way to
+	SuggestedFix string `json:"suggestedFix,omitempty"`
+}
+
+// String formats the diagnostic like a compiler error
+func (d Diagnostic) String() string {
+	msg := fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+
+	if d.Code != "" {
+		msg += " [" + d.Code + "]"
+	}
+
+	if d.SuggestedFix != "" {
+		msg += "\n\tfix: " + d.SuggestedFix
+	}
+
+	return msg
+}
+
+// suggestedFixes are the fixes of the problems that have a usual one, by code
+var suggestedFixes = map[string]string{
+	"Conflict":         "rename one of the errors, or make their messages, argument types and leading AddTrait and AddOp calls the same",
+	"DynamicFormat":    "use a constant format string, e.g. \"NotFound: %s was not found\", or leave the call as is",
+	"NoErrorName":      "start the message with the error name, e.g. \"NotFound: %s was not found\"",
+	"NoErrorMessage":   "give the error a message, e.g. \"NotFound: %s was not found\"",
+	"ImportCycle":      "use the package or file layout, or move the argument's type out of the package",
+	"InvalidFieldName": "name the argument with a Go identifier the generated error doesn't already use",
+	"NotPruned":        "use --force to prune anyway",
+}
+
+// nonFatalCodes are the problems of call sites that may be left as they are on purpose
+var nonFatalCodes = []string{"DynamicFormat", "NotPruned"}
+
+// newDiagnostic describes err at pos, with the severity and fix that go with its ID
+func newDiagnostic(pos token.Position, err error) Diagnostic {
+	code := grr.ID(err)
+	severity := SeverityError
+
+	if utils.Contains(nonFatalCodes, code) {
+		severity = SeverityWarning
+	}
+
+	return Diagnostic{
+		File:         pos.Filename,
+		Line:         pos.Line,
+		Column:       pos.Column,
+		Severity:     severity,
+		Code:         code,
+		Message:      strings.TrimPrefix(err.Error(), code+": "),
+		SuggestedFix: suggestedFixes[code],
+	}
+}
+
+// newInfo describes what was done at pos
+func newInfo(pos token.Position, code string, format string, args ...any) Diagnostic {
+	return Diagnostic{
+		File:     pos.Filename,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Severity: SeverityInfo,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
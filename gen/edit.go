@@ -0,0 +1,238 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackHedaya/grr/utils"
+)
+
+// textEdit replaces the bytes of a file between two offsets
+type textEdit struct {
+	start int
+	end   int
+	text  string
+	// The grr.Errorf call the edit converts, unset for the edits of imports
+	call token.Pos
+}
+
+// addEdit records that the source between from and to is replaced by text, to convert call
+func (walker *grrWalker) addEdit(call *ast.CallExpr, from, to token.Pos, text string) {
+	start := walker.fset.Position(from)
+	end := walker.fset.Position(to)
+
+	walker.edits[start.Filename] = append(walker.edits[start.Filename], textEdit{
+		start: start.Offset,
+		end:   end.Offset,
+		text:  text,
+		call:  call.Pos(),
+	})
+}
+
+// applyEdits applies non-overlapping edits to src, leaving every other byte untouched
+func applyEdits(src []byte, edits []textEdit) []byte {
+	edits = slices.Clone(edits)
+
+	slices.SortFunc(edits, func(a, b textEdit) int {
+		return a.start - b.start
+	})
+
+	var out strings.Builder
+	last := 0
+
+	for _, edit := range edits {
+		out.Write(src[last:edit.start])
+		out.WriteString(edit.text)
+		last = edit.end
+	}
+
+	out.Write(src[last:])
+
+	return []byte(out.String())
+}
+
+// importEdits removes the grr imports of a file that are no longer used once its grr.Errorf calls are replaced,
+// and adds the import of the errors package when the call sites now refer to it. The errors package takes the place
+// of a removed grr import where there is one, so that it lands in the same import group
+func (walker *grrWalker) importEdits(file *ast.File, src []byte) []textEdit {
+	tokFile := walker.fset.File(file.Pos())
+	edits := []textEdit{}
+
+	addedSpec := ""
+
+	if name, ok := walker.addedImports[file]; ok {
+		pkgName, pkgPath := errorsPackage(walker.pkg, walker.cfg)
+		addedSpec = strconv.Quote(pkgPath)
+
+		if name != pkgName {
+			addedSpec = name + " " + addedSpec
+		}
+	}
+
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		unused := []*ast.ImportSpec{}
+
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+
+			isGrr := utils.Contains(walker.cfg.ImportPaths, strings.Trim(importSpec.Path.Value, "\""))
+
+			if (isGrr || walker.hasReplacedUse(file, importSpec)) && !walker.stillUsed(file, importSpec) {
+				unused = append(unused, importSpec)
+			}
+		}
+
+		if len(unused) == 0 {
+			continue
+		}
+
+		// drop the whole declaration rather than leaving an empty import ()
+		if len(unused) == len(genDecl.Specs) {
+			if addedSpec != "" {
+				edits = append(edits, textEdit{start: tokFile.Offset(genDecl.Pos()), end: tokFile.Offset(genDecl.End()), text: "import " + addedSpec})
+				addedSpec = ""
+				continue
+			}
+
+			edit := lineEdit(src, tokFile.Offset(genDecl.Pos()), tokFile.Offset(genDecl.End()))
+
+			// don't leave the blank lines on both sides of the declaration behind
+			if edit.start >= 2 && src[edit.start-1] == '\n' && src[edit.start-2] == '\n' &&
+				edit.end < len(src) && src[edit.end] == '\n' {
+				edit.end++
+			}
+
+			edits = append(edits, edit)
+			continue
+		}
+
+		for _, spec := range unused {
+			if addedSpec != "" {
+				edits = append(edits, textEdit{start: tokFile.Offset(spec.Pos()), end: tokFile.Offset(spec.End()), text: addedSpec})
+				addedSpec = ""
+				continue
+			}
+
+			edit := lineEdit(src, tokFile.Offset(spec.Pos()), tokFile.Offset(spec.End()))
+
+			// don't leave the blank line that separated the last import group behind
+			if edit.start >= 2 && src[edit.start-1] == '\n' && src[edit.start-2] == '\n' &&
+				strings.HasPrefix(strings.TrimLeft(string(src[edit.end:]), " \t"), ")") {
+				edit.start--
+			}
+
+			edits = append(edits, edit)
+		}
+	}
+
+	if addedSpec != "" {
+		edits = append(edits, addImportEdit(file, tokFile, addedSpec))
+	}
+
+	return edits
+}
+
+// addImportEdit adds spec to the first import declaration of a file, or declares it after the package clause if there is none
+func addImportEdit(file *ast.File, tokFile *token.File, spec string) textEdit {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		if genDecl.Rparen.IsValid() {
+			offset := tokFile.Offset(genDecl.Rparen)
+			return textEdit{start: offset, end: offset, text: "\t" + spec + "\n"}
+		}
+
+		offset := tokFile.Offset(genDecl.End())
+		return textEdit{start: offset, end: offset, text: "\nimport " + spec}
+	}
+
+	offset := tokFile.Offset(file.Name.End())
+
+	return textEdit{start: offset, end: offset, text: "\n\nimport " + spec}
+}
+
+// stillUsed reports whether the package imported by spec is referenced by anything but the replaced grr.Errorf calls
+func (walker *grrWalker) stillUsed(file *ast.File, spec *ast.ImportSpec) bool {
+	pkgName := walker.info.PkgNameOf(spec)
+
+	if pkgName == nil {
+		return true
+	}
+
+	for ident, obj := range walker.info.Uses {
+		if obj != pkgName || ident.Pos() < file.Pos() || ident.Pos() > file.End() {
+			continue
+		}
+
+		if !walker.replaced.Has(ident) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasReplacedUse reports whether a use of an import was removed from the file, e.g. along with a lifted AddTrait call
+func (walker *grrWalker) hasReplacedUse(file *ast.File, spec *ast.ImportSpec) bool {
+	pkgName := walker.info.PkgNameOf(spec)
+
+	if pkgName == nil {
+		return false
+	}
+
+	for _, ident := range walker.replaced.ToSlice() {
+		if walker.info.Uses[ident] == pkgName && ident.Pos() >= file.Pos() && ident.Pos() <= file.End() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markReplaced records that the package identifiers of exprs are removed from the source
+func (walker *grrWalker) markReplaced(exprs ...ast.Expr) {
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				if _, ok := walker.info.Uses[ident].(*types.PkgName); ok {
+					walker.replaced.Add(ident)
+				}
+			}
+
+			return true
+		})
+	}
+}
+
+// lineEdit removes the source between start and end, along with its whole lines when nothing else is on them
+func lineEdit(src []byte, start, end int) textEdit {
+	lineStart := start
+	for lineStart > 0 && (src[lineStart-1] == ' ' || src[lineStart-1] == '\t') {
+		lineStart--
+	}
+
+	lineEnd := end
+	for lineEnd < len(src) && (src[lineEnd] == ' ' || src[lineEnd] == '\t') {
+		lineEnd++
+	}
+
+	if (lineStart == 0 || src[lineStart-1] == '\n') && (lineEnd == len(src) || src[lineEnd] == '\n') {
+		return textEdit{start: lineStart, end: min(lineEnd+1, len(src))}
+	}
+
+	return textEdit{start: start, end: end}
+}
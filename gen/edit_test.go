@@ -0,0 +1,186 @@
+package gen
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"testing"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/utils"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestApplyEdits(t *testing.T) {
+	src := []byte("0123456789")
+
+	tests := []struct {
+		name  string
+		edits []textEdit
+		want  string
+	}{
+		{name: "none", edits: nil, want: "0123456789"},
+		{name: "replace", edits: []textEdit{{start: 2, end: 4, text: "ab"}}, want: "01ab456789"},
+		{name: "insert", edits: []textEdit{{start: 10, end: 10, text: "!"}}, want: "0123456789!"},
+		{name: "delete", edits: []textEdit{{start: 0, end: 3}}, want: "3456789"},
+		{
+			name:  "unsorted",
+			edits: []textEdit{{start: 8, end: 9, text: "x"}, {start: 1, end: 2, text: "y"}, {start: 5, end: 5, text: "z"}},
+			want:  "0y234z567x9",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := string(applyEdits(src, test.edits)); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// fakeImporter imports packages declaring nothing but a func Errorf(format string) error, named after the last element of their path
+var fakeImporter = importerFunc(func(importPath string) (*types.Package, error) {
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	params := types.NewTuple(types.NewParam(token.NoPos, pkg, "format", types.Typ[types.String]))
+	results := types.NewTuple(types.NewParam(token.NoPos, pkg, "", types.Universe.Lookup("error").Type()))
+	sig := types.NewSignatureType(nil, nil, nil, params, results, false)
+
+	pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, "Errorf", sig))
+	pkg.MarkComplete()
+
+	return pkg, nil
+})
+
+// convertFile replaces the grr.Errorf calls of src with calls of convertTo, and returns the result of its import edits
+func convertFile(t *testing.T, src string, convertTo string, cfg *config.Config) string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &types.Info{Uses: map[*ast.Ident]types.Object{}, Defs: map[*ast.Ident]types.Object{}, Implicits: map[ast.Node]types.Object{}}
+	typesPkg, err := (&types.Config{Importer: fakeImporter}).Check("example.com/p", fset, []*ast.File{file}, info)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walker := &grrWalker{
+		cfg:          cfg,
+		fset:         fset,
+		info:         info,
+		pkg:          &packages.Package{Name: typesPkg.Name(), PkgPath: typesPkg.Path(), Types: typesPkg},
+		edits:        map[string][]textEdit{},
+		replaced:     utils.NewSet[*ast.Ident](),
+		addedImports: map[*ast.File]string{},
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+
+		if !ok {
+			return true
+		}
+
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Errorf" && sel.X.(*ast.Ident).Name == "grr" {
+			walker.addEdit(call, call.Pos(), call.End(), convertTo)
+			walker.markReplaced(call.Fun)
+
+			if cfg.Layout == config.LayoutSubpackage {
+				walker.addedImports[file] = path.Base(cfg.ErrorsPackage)
+			}
+		}
+
+		return true
+	})
+
+	edits := append(walker.edits["p.go"], walker.importEdits(file, []byte(src))...)
+
+	return string(applyEdits([]byte(src), edits))
+}
+
+func TestImportEdits(t *testing.T) {
+	subpackage := config.Default()
+	subpackage.Layout = config.LayoutSubpackage
+	subpackage.ErrorsPackage = "errs"
+
+	tests := []struct {
+		name      string
+		src       string
+		convertTo string
+		cfg       *config.Config
+		want      string
+	}{
+		{
+			name:      "single import",
+			src:       "package p\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nvar err = grr.Errorf(\"x\")\n",
+			convertTo: "X()",
+			want:      "package p\n\nvar err = X()\n",
+		},
+		{
+			name:      "single import in a group",
+			src:       "package p\n\nimport (\n\t\"github.com/jackHedaya/grr/grr\"\n)\n\nvar err = grr.Errorf(\"x\")\n",
+			convertTo: "X()",
+			want:      "package p\n\nvar err = X()\n",
+		},
+		{
+			name:      "last import group",
+			src:       "package p\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/jackHedaya/grr/grr\"\n)\n\nvar err = grr.Errorf(\"x\")\nvar _ = fmt.Errorf\n",
+			convertTo: "X()",
+			want:      "package p\n\nimport (\n\t\"fmt\"\n)\n\nvar err = X()\nvar _ = fmt.Errorf\n",
+		},
+		{
+			name:      "first of a group",
+			src:       "package p\n\nimport (\n\t\"github.com/jackHedaya/grr/grr\"\n\t\"fmt\"\n)\n\nvar err = grr.Errorf(\"x\")\nvar _ = fmt.Errorf\n",
+			convertTo: "X()",
+			want:      "package p\n\nimport (\n\t\"fmt\"\n)\n\nvar err = X()\nvar _ = fmt.Errorf\n",
+		},
+		{
+			name:      "still used",
+			src:       "package p\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nvar err = grr.Errorf(\"x\")\nvar _ = grr.Errorf\n",
+			convertTo: "X()",
+			want:      "package p\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nvar err = X()\nvar _ = grr.Errorf\n",
+		},
+		{
+			name:      "errors package takes the place of grr",
+			src:       "package p\n\nimport (\n\t\"fmt\"\n\n\t\"github.com/jackHedaya/grr/grr\"\n)\n\nvar err = grr.Errorf(\"x\")\nvar _ = fmt.Errorf\n",
+			convertTo: "errs.X()",
+			cfg:       subpackage,
+			want:      "package p\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/p/errs\"\n)\n\nvar err = errs.X()\nvar _ = fmt.Errorf\n",
+		},
+		{
+			name:      "errors package replaces the declaration",
+			src:       "package p\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nvar err = grr.Errorf(\"x\")\n",
+			convertTo: "errs.X()",
+			cfg:       subpackage,
+			want:      "package p\n\nimport \"example.com/p/errs\"\n\nvar err = errs.X()\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := test.cfg
+
+			if cfg == nil {
+				cfg = config.Default()
+			}
+
+			if got := convertFile(t, test.src, test.convertTo, cfg); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
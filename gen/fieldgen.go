@@ -7,43 +7,59 @@ import (
 	"go/printer"
 	"go/token"
 	"go/types"
+	"path"
 	"strings"
+	"unicode"
 )
 
 type FieldGenerator struct {
 	fset       *token.FileSet
 	info       *types.Info
+	pkg        *types.Package
 	nameCounts map[string]int
+	// The numbered names given to arguments whose derived names were taken, along with the taken name
+	fallbacks map[string]string
+	// Import paths of the packages referenced by the generated field types
+	imports []string
 }
 
-func NewFieldGenerator(fset *token.FileSet, info *types.Info) *FieldGenerator {
+// NewFieldGenerator returns a FieldGenerator for fields generated into pkg, which refer to the types of pkg unqualified.
+// With a nil pkg every type is qualified
+func NewFieldGenerator(fset *token.FileSet, info *types.Info, pkg *types.Package) *FieldGenerator {
 	return &FieldGenerator{
-		fset: fset,
-		info: info,
+		fset:      fset,
+		info:      info,
+		pkg:       pkg,
+		fallbacks: map[string]string{},
 		nameCounts: map[string]int{
 			// Ensuring that the intrinsic names are unique
-			"err":    1,
-			"traits": 1,
-			"op":     1,
+			"err":     1,
+			"traits":  1,
+			"op":      1,
+			"created": 1,
 		},
 	}
 }
 
 func (fg *FieldGenerator) GenerateField(arg ast.Expr) GrrGenErrorField {
+	return fg.GenerateNamedField(arg, fg.generateName(arg))
+}
+
+// GenerateNamedField generates the field for arg with the given name, e.g. from a placeholder. The name must have been reserved
+func (fg *FieldGenerator) GenerateNamedField(arg ast.Expr, name string) GrrGenErrorField {
 	var buf bytes.Buffer
 	var ttype string
 
 	printer.Fprint(&buf, fg.fset, arg)
 
-	if tv, ok := fg.info.Types[arg]; ok && tv.Type != nil {
-		ttype = tv.Type.String()
+	// the type parameters of the enclosing function aren't in scope in the generated file, so their values are taken as any
+	if tv, ok := fg.info.Types[arg]; ok && tv.Type != nil && !hasTypeParam(tv.Type) {
+		ttype = types.TypeString(tv.Type, fg.qualifier)
 
 	} else {
 		ttype = "any"
 	}
 
-	name := fg.generateName(arg)
-
 	return GrrGenErrorField{
 		Name: name,
 		Expr: buf.String(),
@@ -51,14 +67,84 @@ func (fg *FieldGenerator) GenerateField(arg ast.Expr) GrrGenErrorField {
 	}
 }
 
+// hasTypeParam reports whether typ refers to a type parameter, e.g. K, []K or map[string]Cache[K]
+func hasTypeParam(typ types.Type) bool {
+	switch t := typ.(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Pointer:
+		return hasTypeParam(t.Elem())
+	case *types.Slice:
+		return hasTypeParam(t.Elem())
+	case *types.Array:
+		return hasTypeParam(t.Elem())
+	case *types.Chan:
+		return hasTypeParam(t.Elem())
+	case *types.Map:
+		return hasTypeParam(t.Key()) || hasTypeParam(t.Elem())
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if hasTypeParam(t.At(i).Type()) {
+				return true
+			}
+		}
+	case *types.Signature:
+		return hasTypeParam(t.Params()) || hasTypeParam(t.Results())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasTypeParam(t.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Named:
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if hasTypeParam(t.TypeArgs().At(i)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Reserve keeps generated names from using name
+func (fg *FieldGenerator) Reserve(name string) {
+	fg.nameCounts[name] = max(fg.nameCounts[name], 1)
+}
+
+// Fallback returns the name an argument named name was derived from, when name is a numbered fallback
+// because the derived name was taken, e.g. err for err2
+func (fg *FieldGenerator) Fallback(name string) (string, bool) {
+	taken, ok := fg.fallbacks[name]
+
+	return taken, ok
+}
+
+// Imports returns the import paths needed by the types of the fields generated so far
+func (fg *FieldGenerator) Imports() []string {
+	return fg.imports
+}
+
+// qualifier writes types the way they are referred to from the generated file: unqualified for the
+// package itself and by package name otherwise. Every package it sees is recorded as an import
+func (fg *FieldGenerator) qualifier(pkg *types.Package) string {
+	if pkg == fg.pkg {
+		return ""
+	}
+
+	fg.imports = append(fg.imports, pkg.Path())
+
+	return pkg.Name()
+}
+
 func (fg *FieldGenerator) generateName(arg ast.Expr) string {
 	nameCounts := fg.nameCounts
 	info := fg.info
 
 	var name string
 
-	if ident, ok := arg.(*ast.Ident); ok {
-		name = ident.Name
+	if exprName := sanitizeName(fg.exprName(arg)); exprName != "" {
+		name = exprName
 
 	} else if lit, ok := arg.(*ast.BasicLit); ok {
 		name = strings.ToLower(lit.Kind.String())
@@ -85,13 +171,233 @@ func (fg *FieldGenerator) generateName(arg ast.Expr) string {
 		name = "arg"
 	}
 
-	// Ensuring the name is unique
-	if _, ok := nameCounts[name]; ok {
-		nameCounts[name]++
-		name = fmt.Sprintf("%s%d", name, nameCounts[name])
-	} else {
+	if name == "" {
+		name = "arg"
+	}
+
+	if _, ok := nameCounts[name]; !ok {
 		nameCounts[name] = 1
+		return name
+	}
+
+	// a taken name gives way to one after the type of the argument, e.g. pathErr for an err of type *fs.PathError
+	if typeName := fg.typeName(arg); typeName != "" {
+		if _, ok := nameCounts[typeName]; !ok {
+			nameCounts[typeName] = 1
+			return typeName
+		}
+	}
+
+	taken := name
+
+	for {
+		nameCounts[taken]++
+		name = fmt.Sprintf("%s%d", taken, nameCounts[taken])
+
+		if _, ok := nameCounts[name]; !ok {
+			break
+		}
 	}
 
+	nameCounts[name] = 1
+	fg.fallbacks[name] = taken
+
 	return name
 }
+
+// typeName names an argument after its named type, e.g. userID for UserID and pathErr for *fs.PathError.
+// It returns "" for the predeclared and unnamed types, which don't tell arguments apart
+func (fg *FieldGenerator) typeName(arg ast.Expr) string {
+	tv, ok := fg.info.Types[arg]
+
+	if !ok || tv.Type == nil {
+		return ""
+	}
+
+	typ := tv.Type
+
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+
+	named, ok := typ.(*types.Named)
+
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+
+	name := named.Obj().Name()
+
+	if trimmed := strings.TrimSuffix(name, "Error"); trimmed != "" && trimmed != name {
+		name = trimmed + "Err"
+	}
+
+	return sanitizeName(name)
+}
+
+// exprName derives a name from what expr refers to, e.g. userID for user.ID, itemsLen for len(items),
+// headerX for req.Header.Get("X") and xsElem for xs[i]. It returns "" for expressions it can't name
+func (fg *FieldGenerator) exprName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+
+	case *ast.ParenExpr:
+		return fg.exprName(e.X)
+
+	// *p, &p, !ok and -n are named after their operand
+	case *ast.StarExpr:
+		return fg.exprName(e.X)
+
+	case *ast.UnaryExpr:
+		return fg.exprName(e.X)
+
+	case *ast.SliceExpr:
+		return fg.exprName(e.X)
+
+	case *ast.TypeAssertExpr:
+		return fg.exprName(e.X)
+
+	// m["key"] => mKey
+	case *ast.IndexExpr:
+		if key, ok := constantString(fg.info, e.Index); ok {
+			return joinName(fg.exprName(e.X), key)
+		}
+
+		return joinName(fg.exprName(e.X), "Elem")
+
+	case *ast.SelectorExpr:
+		if fg.isPackage(e.X) {
+			return e.Sel.Name
+		}
+
+		return joinName(fg.lastName(e.X), e.Sel.Name)
+
+	case *ast.CallExpr:
+		return fg.callName(e)
+
+	case *ast.FuncLit:
+		return "fn"
+	}
+
+	return ""
+}
+
+// callName names the result of a call
+func (fg *FieldGenerator) callName(call *ast.CallExpr) string {
+	fun := call.Fun
+
+	for paren, ok := fun.(*ast.ParenExpr); ok; paren, ok = fun.(*ast.ParenExpr) {
+		fun = paren.X
+	}
+
+	// conversions like string(b) or int64(n) don't change what the value is
+	if tv, ok := fg.info.Types[fun]; ok && tv.IsType() && len(call.Args) == 1 {
+		return fg.exprName(call.Args[0])
+	}
+
+	if ident, ok := fun.(*ast.Ident); ok {
+		// len(items) => itemsLen
+		if _, ok := fg.info.Uses[ident].(*types.Builtin); ok && len(call.Args) == 1 {
+			return joinName(fg.exprName(call.Args[0]), ident.Name)
+		}
+
+		return ident.Name
+	}
+
+	selExpr, ok := fun.(*ast.SelectorExpr)
+
+	if !ok {
+		return ""
+	}
+
+	if fg.isPackage(selExpr.X) {
+		return selExpr.Sel.Name
+	}
+
+	// req.Header.Get("X") => headerX
+	if len(call.Args) == 1 {
+		if key, ok := constantString(fg.info, call.Args[0]); ok {
+			return joinName(fg.lastName(selExpr.X), key)
+		}
+	}
+
+	// user.GetName() => userName
+	method := selExpr.Sel.Name
+
+	if trimmed := strings.TrimPrefix(method, "Get"); trimmed != "" {
+		method = trimmed
+	}
+
+	return joinName(fg.lastName(selExpr.X), method)
+}
+
+// lastName names the last part of expr, e.g. header for req.Header
+func (fg *FieldGenerator) lastName(expr ast.Expr) string {
+	if selExpr, ok := expr.(*ast.SelectorExpr); ok {
+		return selExpr.Sel.Name
+	}
+
+	return fg.exprName(expr)
+}
+
+// isPackage reports whether expr is the name of an imported package
+func (fg *FieldGenerator) isPackage(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+
+	if !ok {
+		return false
+	}
+
+	_, ok = fg.info.Uses[ident].(*types.PkgName)
+
+	return ok
+}
+
+// joinName appends the words of suffix to name in camel case
+func joinName(name string, suffix string) string {
+	if name == "" {
+		return ""
+	}
+
+	return name + strings.Join(titleWords(suffix), "")
+}
+
+// sanitizeName turns a derived name into a valid unexported identifier that isn't reserved, or "" if nothing is left of it
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+
+		return -1
+	}, name)
+
+	name = untitle(strings.TrimLeftFunc(name, unicode.IsDigit))
+
+	if name == "" || name == "_" {
+		return ""
+	}
+
+	if isReservedName(name) {
+		return name + "Val"
+	}
+
+	return name
+}
+
+// isReservedName reports whether name can't be a constructor parameter: keywords, and the predeclared identifiers
+// and packages the generated code refers to, which a parameter would shadow
+func isReservedName(name string) bool {
+	if token.IsKeyword(name) || types.Universe.Lookup(name) != nil || name == "grr" {
+		return true
+	}
+
+	for _, importPath := range GenDefaultImports() {
+		if path.Base(importPath) == name {
+			return true
+		}
+	}
+
+	return false
+}
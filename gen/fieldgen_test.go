@@ -0,0 +1,76 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+)
+
+const fieldNamesMain = `package main
+
+import (
+	"io/fs"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+type User struct {
+	ID   int
+	Tags map[string]string
+}
+
+func main() {}
+
+func (u *User) GetName() string { return "" }
+
+func Derived(user *User, items []string, p *int) error {
+	return grr.Errorf("Lookup: %d %d %s %s %d %s", user.ID, len(items), user.Tags["team"], user.GetName(), *p, items[0])
+}
+
+func Renamed(src, dst *User) error {
+	return grr.Errorf("Copy: %s to %s",
+		src.GetName(), // grr:name from
+		dst.GetName(), //grr:name to
+	)
+}
+
+func Typed(path string, err *fs.PathError) error {
+	return grr.Errorf("OpenFailed: %s: %v", path, err)
+}
+
+func Numbered(e error, f error) error {
+	return grr.Errorf("Twice: %v and %v", e, e)
+}
+`
+
+// Arguments are named after their expressions, //grr:name comments override them, and taken names
+// give way to the type of the argument before falling back to a number, which is reported
+func TestFieldNames(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": fieldNamesMain})
+
+	collisions := []Diagnostic{}
+
+	for _, diagnostic := range generate(t, dir) {
+		if diagnostic.Code == "NameCollision" {
+			collisions = append(collisions, diagnostic)
+		} else if diagnostic.Severity != SeverityInfo {
+			t.Errorf("unexpected diagnostic %s", diagnostic)
+		}
+	}
+
+	generated := readFile(t, dir, "grr.gen.go")
+
+	for _, want := range []string{
+		"func NewErrLookup(userID int, itemsLen int, userTagsTeam string, userName string, p int, itemsElem string) *ErrLookup {",
+		"func NewErrCopy(from string, to string) *ErrCopy {",
+		"func NewErrOpenFailed(path string, pathErr *fs.PathError) *ErrOpenFailed {",
+		"func NewErrTwice(e error, e2 error) *ErrTwice {",
+	} {
+		if !strings.Contains(generated, want) {
+			t.Errorf("grr.gen.go doesn't contain %s:\n%s", want, generated)
+		}
+	}
+
+	if len(collisions) != 1 || !strings.Contains(collisions[0].Message, "argument #2 of ErrTwice is named e2 since e is taken") || !strings.Contains(collisions[0].Message, "//grr:name") {
+		t.Errorf("got collisions %v, want one for the second argument of ErrTwice suggesting //grr:name", collisions)
+	}
+}
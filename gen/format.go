@@ -0,0 +1,125 @@
+package gen
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackHedaya/grr/utils"
+)
+
+// formatVerb is a verb of a printf format string
+type formatVerb struct {
+	// The verb itself, e.g. 's' for %s
+	Verb rune
+	// The flags written before the width, e.g. "+#"
+	Flags string
+	// The index of the argument the verb formats, or -1 for %%
+	Arg int
+	// The indexes of the arguments * widths and precisions take, or -1
+	WidthArg int
+	PrecArg  int
+	// Offsets of the verb in the format string, from its % to its verb
+	Start int
+	End   int
+}
+
+// parseFormat returns the verbs of a printf format string in order, resolving explicit argument indexes like %[2]d
+// the way fmt does. It also returns the number of arguments the verbs consume
+func parseFormat(format string) ([]formatVerb, int) {
+	verbs := []formatVerb{}
+	argNum := 0
+	maxArg := 0
+
+	for idx := 0; idx < len(format); idx++ {
+		if format[idx] != '%' {
+			continue
+		}
+
+		verb := formatVerb{Start: idx, Arg: -1, WidthArg: -1, PrecArg: -1}
+		idx++
+
+		for idx < len(format) && strings.ContainsRune("+-# 0", rune(format[idx])) {
+			verb.Flags += string(format[idx])
+			idx++
+		}
+
+		// an explicit index applies to whatever takes the next argument
+		argIndex := func() {
+			if idx < len(format) && format[idx] == '[' {
+				if end := strings.IndexByte(format[idx:], ']'); end > 0 {
+					if n, err := strconv.Atoi(format[idx+1 : idx+end]); err == nil && n > 0 {
+						argNum = n - 1
+					}
+
+					idx += end + 1
+				}
+			}
+		}
+
+		argIndex()
+
+		if idx < len(format) && format[idx] == '*' {
+			verb.WidthArg = argNum
+			argNum++
+			idx++
+		} else {
+			for idx < len(format) && format[idx] >= '0' && format[idx] <= '9' {
+				idx++
+			}
+		}
+
+		if idx < len(format) && format[idx] == '.' {
+			idx++
+			argIndex()
+
+			if idx < len(format) && format[idx] == '*' {
+				verb.PrecArg = argNum
+				argNum++
+				idx++
+			} else {
+				for idx < len(format) && format[idx] >= '0' && format[idx] <= '9' {
+					idx++
+				}
+			}
+		}
+
+		argIndex()
+
+		if idx >= len(format) {
+			verb.End = len(format)
+			verbs = append(verbs, verb)
+			break
+		}
+
+		r, size := utf8.DecodeRuneInString(format[idx:])
+
+		verb.Verb = r
+		verb.End = idx + size
+		idx += size - 1
+
+		if r != '%' {
+			verb.Arg = argNum
+			argNum++
+		}
+
+		maxArg = max(maxArg, argNum, verb.WidthArg+1, verb.PrecArg+1)
+		verbs = append(verbs, verb)
+	}
+
+	return verbs, maxArg
+}
+
+// causeIndexes returns the indexes of the arguments a format string wraps with %w, in order and without duplicates
+func causeIndexes(format string) []int {
+	verbs, _ := parseFormat(format)
+	indexes := []int{}
+
+	for _, verb := range verbs {
+		if verb.Verb == 'w' && verb.Arg >= 0 && !utils.Contains(indexes, verb.Arg) {
+			indexes = append(indexes, verb.Arg)
+		}
+	}
+
+	return indexes
+}
@@ -1,170 +1,393 @@
 package gen
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"go/ast"
-	"go/format"
-	"go/printer"
+	"go/parser"
 	"go/token"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/jackHedaya/grr/config"
 	"github.com/jackHedaya/grr/grr"
 	"github.com/jackHedaya/grr/utils"
 	"golang.org/x/tools/go/packages"
 )
 
+// GenerateOptions controls how GenerateEntry applies its changes
+type GenerateOptions struct {
+	// DryRun prints a unified diff of every file that would change instead of writing anything
+	DryRun bool
+	// Out receives the diffs of a dry run. Defaults to os.Stdout
+	Out io.Writer
+	// TypeCheck type-checks the packages with their changes applied before anything is written
+	TypeCheck bool
+	// Config is the project configuration. Defaults to config.Default()
+	Config *config.Config
+	// Log receives progress messages. Defaults to os.Stdout
+	Log io.Writer
+}
+
+// fileChange is the new content of a file written by a generation run
+type fileChange struct {
+	path    string
+	content []byte
+	// The file is deleted instead of written
+	remove bool
+}
+
 // GenerateEntry processes all Go files in a directory to find and report grr.Errorf calls.
-func GenerateEntry(directory string) error {
-	// Ensure the directory path is absolute
-	dir, err := utils.ResolveAbsoluteDir(directory)
+// It returns whether any file was changed, or would be changed in a dry run, along with the diagnostics of every call site
+// and of the packages that were skipped. Only problems that keep the whole run from completing are returned as errors
+func GenerateEntry(directory string, opts GenerateOptions) (bool, []Diagnostic, error) {
+	cfg := opts.Config
 
-	if err != nil {
-		return grr.Errorf("unable to determine directory").AddError(err)
+	if cfg == nil {
+		cfg = config.Default()
 	}
 
-	// Set up the configuration to load the packages correctly
-	cfg := &packages.Config{
-		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
-		Dir:  dir,
+	log := opts.Log
+
+	if log == nil {
+		log = os.Stdout
 	}
 
-	// Load all packages in the directory
-	pkgs, err := packages.Load(cfg, "./...")
+	tmpls, err := LoadTemplates(cfg)
+
 	if err != nil {
-		return grr.Errorf("FailedToLoadPackages: failed to load packages").AddError(err)
+		return false, nil, err
 	}
 
-	if len(pkgs) == 0 {
-		return grr.Errorf("NoPackagesFound: no packages found in directory. string builder for testing: %v", strings.Builder{})
+	pkgs, err := loadPackages(directory, cfg)
+
+	if err != nil {
+		return false, nil, err
 	}
 
+	changes := []fileChange{}
+	diagnostics := []Diagnostic{}
+
 	// Process each package
 	for _, pkg := range pkgs {
 		if len(pkg.Errors) > 0 {
-			fmt.Printf("Ignoring package %s due to errors: %v\n", pkg.PkgPath, pkg.Errors)
+			for _, pkgErr := range pkg.Errors {
+				diagnostics = append(diagnostics, newDiagnostic(parsePosition(pkgErr.Pos), grr.Errorf("PackageNotLoaded: package %s is skipped: %s", pkg.PkgPath, pkgErr.Msg)))
+			}
+
 			continue
 		}
 
-		// get previous errors
-		prevErrors, err := LoadPreviousErrors(pkg.PkgPath)
+		pkgChanges, pkgDiagnostics, err := generatePackage(pkg, cfg, tmpls, log)
 
 		if err != nil {
-			return grr.Errorf("FailedToLoadPreviousErrors: failed to load previous errors").AddError(err)
+			return false, diagnostics, err
 		}
 
-		pkgWalker := &grrWalker{
-			fset:            pkg.Fset,
-			info:            pkg.TypesInfo,
-			pkg:             pkg,
-			generatedErrors: map[string]GeneratedError{},
-			prevErrors:      prevErrors,
-			imports:         utils.NewSetFromSlice(GenDefaultImports()),
+		changes = append(changes, pkgChanges...)
+		diagnostics = append(diagnostics, pkgDiagnostics...)
+	}
+
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		if a.File != b.File {
+			return strings.Compare(a.File, b.File)
 		}
 
-		// Load previous errors from grr.gen.go files
-		// err := visitor.LoadPreviousErrors()
-		if len(pkg.GoFiles) != len(pkg.Syntax) {
-			return grr.Errorf("MismatchedGoFilesAndSyntax: mismatched number of Go files and syntax trees")
+		if a.Line != b.Line {
+			return a.Line - b.Line
 		}
 
-		fileToAst := map[string]*ast.File{}
+		return a.Column - b.Column
+	})
+
+	if opts.DryRun {
+		changed, err := printChanges(directory, changes, opts.Out)
 
-		for idx, astFile := range pkg.Syntax {
-			fileToAst[pkg.GoFiles[idx]] = astFile
-			ast.Walk(pkgWalker, astFile)
+		return changed, diagnostics, err
+	}
+
+	if opts.TypeCheck {
+		if err := typeCheck(directory, cfg, changes); err != nil {
+			return false, diagnostics, err
 		}
+	}
 
-		pkgPath, err := utils.GetPackagePath(pkg)
+	for _, change := range changes {
+		if change.remove {
+			fmt.Fprintf(log, "Removing: %s\n", change.path)
+		} else {
+			fmt.Fprintf(log, "Writing to: %s\n", change.path)
+		}
+	}
 
-		if err != nil {
-			return grr.Errorf("FailedToGetPackagePath: failed to get package path").AddError(err)
+	if err := commitChanges(changes); err != nil {
+		return false, diagnostics, grr.Errorf("FailedToWriteFile: failed to write generated files").AddError(err)
+	}
+
+	return len(changes) > 0, diagnostics, nil
+}
+
+// typeCheck loads the packages under directory as if the changes had been written and fails if any package they touch has errors
+func typeCheck(directory string, cfg *config.Config, changes []fileChange) error {
+	overlay := map[string][]byte{}
+	changed := map[string]bool{}
+
+	for _, change := range changes {
+		overlay[change.path] = change.content
+
+		// an overlay can't remove a file, so a removed one is left with nothing but its package clause
+		if change.remove {
+			file, err := parser.ParseFile(token.NewFileSet(), change.path, nil, parser.PackageClauseOnly)
+
+			if err != nil {
+				return grr.Errorf("FailedToParse: failed to parse %s", change.path).AddError(err)
+			}
+
+			overlay[change.path] = []byte("package " + file.Name.Name + "\n")
 		}
 
-		code, err := GenerateErrorFile(pkg.Name, pkgWalker.imports.ToSlice(), pkgWalker.generatedErrors)
+		changed[change.path] = true
+	}
+
+	pkgs, err := loadPackagesWithOverlay(directory, cfg, overlay)
 
-		if err != nil {
-			return grr.Errorf("GenerateErrorFile: failed to generate error file").AddError(err)
+	if err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgs {
+		touched := false
+
+		for _, file := range pkg.GoFiles {
+			touched = touched || changed[file]
 		}
 
-		if len(pkg.GoFiles) == 0 {
-			fmt.Printf("No Go files found in package: %s\n", pkg.PkgPath)
-			continue
+		if touched && len(pkg.Errors) > 0 {
+			return grr.Errorf("TypeCheckFailed: package %s would not compile: %v", pkg.PkgPath, pkg.Errors)
 		}
+	}
 
-		if len(pkgWalker.generatedErrors) == 0 {
-			fmt.Printf("No grr.Errorf calls found in package: %s\n", pkg.PkgPath)
+	return nil
+}
+
+// walkPackage runs a grrWalker over every file of a package except the generated one.
+// It returns the walker along with the walked files by path, or a nil walker if the package has no Go files.
+// Progress is reported to out
+func walkPackage(pkg *packages.Package, cfg *config.Config, tmpls *Templates, out io.Writer) (*grrWalker, map[string]*ast.File, error) {
+	// get the errors already generated into grr.gen.go so they can be merged with the new ones
+	prevErrors, prevImports, err := LoadPreviousErrors(pkg, cfg)
+
+	if err != nil {
+		return nil, nil, grr.Errorf("FailedToLoadPreviousErrors: failed to load previous errors").AddError(err)
+	}
+
+	pkgWalker := &grrWalker{
+		cfg:             cfg,
+		templates:       tmpls,
+		fset:            pkg.Fset,
+		info:            pkg.TypesInfo,
+		pkg:             pkg,
+		generatedErrors: map[string]GeneratedError{},
+		prevErrors:      prevErrors,
+		imports:         utils.NewSetFromSlice(append(GenDefaultImports(), prevImports...)),
+		edits:           map[string][]textEdit{},
+		replaced:        utils.NewSet[*ast.Ident](),
+		chains:          map[*ast.CallExpr][]*ast.CallExpr{},
+		qualifiers:      map[*ast.File]string{},
+		addedImports:    map[*ast.File]string{},
+	}
+
+	if len(pkg.GoFiles) != len(pkg.Syntax) {
+		return nil, nil, grr.Errorf("MismatchedGoFilesAndSyntax: mismatched number of Go files and syntax trees")
+	}
+
+	if len(pkg.GoFiles) == 0 {
+		fmt.Fprintf(out, "No Go files found in package: %s\n", pkg.PkgPath)
+		return nil, nil, nil
+	}
+
+	fileToAst := map[string]*ast.File{}
+	files := []*ast.File{}
+
+	for idx, astFile := range pkg.Syntax {
+		// generated files are rewritten as a whole from the merged errors
+		if cfg.IsGenerated(pkg.GoFiles[idx]) {
 			continue
 		}
 
-		writePath := filepath.Join(pkgPath, "grr.gen.go")
+		fileToAst[pkg.GoFiles[idx]] = astFile
+		files = append(files, astFile)
+	}
+
+	// every call site of an error has to be known before any of them has its defaults lifted
+	pkgWalker.liftable = pkgWalker.sharedDefaults(files)
 
-		fmt.Printf("Writing to: %s\n", writePath)
+	for _, astFile := range files {
+		pkgWalker.file = astFile
+		ast.Walk(pkgWalker, astFile)
+	}
+
+	return pkgWalker, fileToAst, nil
+}
+
+// generatePackage finds the grr.Errorf calls of a package and returns the files that change as a result, along with the
+// diagnostics of its call sites. Progress is reported to log
+func generatePackage(pkg *packages.Package, cfg *config.Config, tmpls *Templates, log io.Writer) ([]fileChange, []Diagnostic, error) {
+	pkgWalker, fileToAst, err := walkPackage(pkg, cfg, tmpls, log)
+
+	if err != nil || pkgWalker == nil {
+		return nil, nil, err
+	}
+
+	layout, err := newOutputLayout(pkg, cfg)
+
+	if err != nil {
+		return nil, pkgWalker.diagnostics, err
+	}
+
+	// errors that are where they belong are only regenerated along with new ones
+	if len(pkgWalker.generatedErrors) == 0 && len(pkgWalker.edits) == 0 && !layout.isMisplaced(pkgWalker.prevErrors) {
+		fmt.Fprintf(log, "No grr.Errorf calls found in package: %s\n", pkg.PkgPath)
+		return nil, pkgWalker.diagnostics, nil
+	}
 
-		err = utils.AppendOrCreate(writePath, code)
+	errors := utils.Merge(pkgWalker.prevErrors, pkgWalker.generatedErrors)
+
+	changes, err := renderOutputs(tmpls, pkg, cfg, layout, errors, pkgWalker.imports.ToSlice())
+
+	if err != nil {
+		return nil, pkgWalker.diagnostics, err
+	}
+
+	// only the files that had call sites replaced are rewritten, and only where they were replaced
+	paths := utils.Keys(pkgWalker.edits)
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
 
 		if err != nil {
-			return grr.Errorf("FailedToWriteFile: failed to write generated file").AddError(err)
+			return nil, pkgWalker.diagnostics, grr.Errorf("FailedToRead: failed to read %s", path).AddError(err)
 		}
 
-		for path, fileNode := range fileToAst {
-			err := writeFile(path, pkg.Fset, fileNode)
-			if err != nil {
-				return grr.Errorf("ASTWriteError: failed to write AST files").AddError(err)
-			}
-		}
+		edits := append(pkgWalker.edits[path], pkgWalker.importEdits(fileToAst[path], src)...)
+
+		changes = append(changes, fileChange{path: path, content: applyEdits(src, edits)})
 	}
 
-	return nil
+	return changes, pkgWalker.diagnostics, nil
 }
 
-func writeFile(path string, fset *token.FileSet, fileNode *ast.File) error {
-	// backup the original file
-	backupPath := path + ".bak"
-	err := os.Rename(path, backupPath)
+// hasChanged reports whether content differs from what is on disk at path. Missing files have changed
+func hasChanged(path string, content []byte) (bool, error) {
+	current, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return true, nil
+	}
 
 	if err != nil {
-		return grr.Errorf("FailedToBackup: failed to backup file").AddError(err)
+		return false, grr.Errorf("FailedToRead: failed to read %s", path).AddError(err)
 	}
 
-	restore := func() {
-		err := os.Rename(backupPath, path)
+	return !bytes.Equal(current, content), nil
+}
 
-		if err != nil {
-			fmt.Printf("Failed to restore backup: %v\n", err)
-		}
+// printChanges writes a unified diff of every change to out
+func printChanges(directory string, changes []fileChange, out io.Writer) (bool, error) {
+	if out == nil {
+		out = os.Stdout
 	}
 
-	out, err := os.Create(path)
+	dir, err := utils.ResolveAbsoluteDir(directory)
+
 	if err != nil {
-		restore()
-		return grr.Errorf("FailedToCreateFile: failed to create file").AddError(err)
+		return false, grr.Errorf("unable to determine directory").AddError(err)
+	}
+
+	for _, change := range changes {
+		name := change.path
+
+		if rel, err := filepath.Rel(dir, change.path); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+
+		current, err := os.ReadFile(change.path)
+		oldName := "a/" + name
+
+		if os.IsNotExist(err) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return false, grr.Errorf("FailedToRead: failed to read %s", change.path).AddError(err)
+		}
+
+		newName := "b/" + name
+
+		if change.remove {
+			newName = "/dev/null"
+		}
+
+		if _, err := io.WriteString(out, utils.UnifiedDiff(oldName, newName, current, change.content)); err != nil {
+			return false, grr.Errorf("FailedToWriteDiff: failed to write diff").AddError(err)
+		}
 	}
 
-	defer out.Close()
+	return len(changes) > 0, nil
+}
+
+// loadPackages loads every package under directory that the config walks, with its syntax and type information
+func loadPackages(directory string, cfg *config.Config) ([]*packages.Package, error) {
+	return loadPackagesWithOverlay(directory, cfg, nil)
+}
 
-	var buf bytes.Buffer
-	bufWriter := bufio.NewWriter(&buf)
+// loadPackagesWithOverlay loads packages like loadPackages, reading the overlay contents instead of the files on disk
+func loadPackagesWithOverlay(directory string, cfg *config.Config, overlay map[string][]byte) ([]*packages.Package, error) {
+	return loadWalkedPackages(directory, cfg, overlay, false)
+}
 
-	if err := printer.Fprint(bufWriter, fset, fileNode); err != nil {
-		restore()
-		return grr.Errorf("FailedToPrint: failed to print AST").AddError(err)
+// loadPackagesWithTests loads packages like loadPackages, along with their test variants and external test packages.
+// Test variants repeat the files of their package, so they are only meant for finding references, e.g. from _test.go files
+func loadPackagesWithTests(directory string, cfg *config.Config) ([]*packages.Package, error) {
+	return loadWalkedPackages(directory, cfg, nil, true)
+}
+
+// loadWalkedPackages loads the packages under directory that the config walks, with their tests when tests is set
+func loadWalkedPackages(directory string, cfg *config.Config, overlay map[string][]byte, tests bool) ([]*packages.Package, error) {
+	// Ensure the directory path is absolute
+	dir, err := utils.ResolveAbsoluteDir(directory)
+
+	if err != nil {
+		return nil, grr.Errorf("unable to determine directory").AddError(err)
 	}
 
-	fmted, err := format.Source(buf.Bytes())
+	// Set up the configuration to load the packages correctly
+	loadCfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+		Dir:     dir,
+		Overlay: overlay,
+		Tests:   tests,
+	}
 
+	// Load all packages in the directory
+	pkgs, err := packages.Load(loadCfg, "./...")
 	if err != nil {
-		restore()
-		return grr.Errorf("FailedToFormat: failed to format source").AddError(err)
+		return nil, grr.Errorf("FailedToLoadPackages: failed to load packages").AddError(err)
 	}
 
-	if _, err := out.Write(fmted); err != nil {
-		restore()
-		return grr.Errorf("FailedToWrite: failed to write to file").AddError(err)
+	if len(pkgs) == 0 {
+		return nil, grr.Errorf("NoPackagesFound: no packages found in directory. string builder for testing: %v", strings.Builder{})
 	}
 
-	return nil
+	walked := []*packages.Package{}
+
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 || cfg.Walks(filepath.Dir(pkg.GoFiles[0])) {
+			walked = append(walked, pkg)
+		}
+	}
+
+	return walked, nil
 }
@@ -0,0 +1,233 @@
+package gen
+
+import (
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/utils"
+)
+
+// writeModule writes files to a new module that uses this copy of grr, and returns its directory
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root, err := filepath.Abs("..")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	files = maps.Clone(files)
+	files["go.mod"] = "module example.com/proj\n\ngo 1.22.2\n\nrequire github.com/jackHedaya/grr v0.0.0\n\nreplace github.com/jackHedaya/grr => " + root + "\n"
+	files["go.sum"] = string(sum)
+
+	writeFiles(t, dir, files)
+
+	// the requirements of grr are only known once go.mod is updated
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOWORK", "off")
+
+	return dir
+}
+
+// generate runs grr gen on dir with the default config and returns the diagnostics
+func generate(t *testing.T, dir string) []Diagnostic {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Root = dir
+
+	return generateWith(t, dir, cfg)
+}
+
+// generateWith runs grr gen on dir with cfg and returns the diagnostics. The output has to type-check
+func generateWith(t *testing.T, dir string, cfg *config.Config) []Diagnostic {
+	t.Helper()
+
+	_, diagnostics, err := GenerateEntry(dir, GenerateOptions{Config: cfg, Log: io.Discard, TypeCheck: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return diagnostics
+}
+
+// readFile returns the content of a file of dir
+func readFile(t *testing.T, dir string, name string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(dir, name))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+// writeFiles writes files to dir, creating their directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// assertConverted fails the test for every diagnostic that isn't about a converted call site
+func assertConverted(t *testing.T, diagnostics []Diagnostic) {
+	t.Helper()
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity != SeverityInfo {
+			t.Errorf("unexpected diagnostic %s", diagnostic)
+		}
+	}
+}
+
+const rerunMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(p string) error {
+	return grr.Errorf("NotFound: %s was not found", p)
+}
+
+func B() error {
+	return grr.Errorf("Timeout: timed out")
+}
+`
+
+const rerunOther = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func C(n int) error {
+	return grr.Errorf("Limit: %d reached", n)
+}
+`
+
+// A second run merges the new errors into grr.gen.go and leaves everything it generated before byte-identical
+func TestRerun(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": rerunMain})
+
+	assertConverted(t, generate(t, dir))
+
+	first := readFile(t, dir, "grr.gen.go")
+
+	cfg := config.Default()
+	cfg.Root = dir
+
+	changed, diagnostics, err := GenerateEntry(dir, GenerateOptions{Config: cfg, Log: io.Discard})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed || len(diagnostics) != 0 {
+		t.Errorf("second run changed = %v with diagnostics %v, want nothing to do", changed, diagnostics)
+	}
+
+	if second := readFile(t, dir, "grr.gen.go"); second != first {
+		t.Errorf("second run changed grr.gen.go:\n%s", utils.UnifiedDiff("first", "second", []byte(first), []byte(second)))
+	}
+
+	writeFiles(t, dir, map[string]string{"other.go": rerunOther})
+	assertConverted(t, generate(t, dir))
+
+	merged := readFile(t, dir, "grr.gen.go")
+
+	for _, name := range []string{"ErrNotFound", "ErrTimeout", "ErrLimit"} {
+		if strings.Count(merged, "// # "+name+"\n") != 1 {
+			t.Errorf("grr.gen.go doesn't declare %s once:\n%s", name, merged)
+		}
+	}
+}
+
+// Only the directories grr.json includes and doesn't exclude are generated
+func TestIncludeExclude(t *testing.T) {
+	pkg := func(name string) string {
+		return "package " + name + "\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nfunc F(p string) error {\n\treturn grr.Errorf(\"NotFound: %s was not found\", p)\n}\n"
+	}
+
+	dir := writeModule(t, map[string]string{
+		"grr.json":        `{"include": ["api"], "exclude": ["api/legacy"]}`,
+		"root.go":         pkg("proj"),
+		"api/api.go":      pkg("api"),
+		"api/v2/v2.go":    pkg("v2"),
+		"api/legacy/l.go": pkg("legacy"),
+		"other/other.go":  pkg("other"),
+	})
+
+	cfg, err := config.Discover(filepath.Join(dir, "api"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertConverted(t, generateWith(t, dir, cfg))
+
+	for _, walked := range []string{"api", "api/v2"} {
+		if _, err := os.Stat(filepath.Join(dir, walked, "grr.gen.go")); err != nil {
+			t.Errorf("%s wasn't generated: %v", walked, err)
+		}
+	}
+
+	for skipped, file := range map[string]string{".": "root.go", "api/legacy": "l.go", "other": "other.go"} {
+		if _, err := os.Stat(filepath.Join(dir, skipped, "grr.gen.go")); err == nil {
+			t.Errorf("%s was generated, want it skipped", skipped)
+		}
+
+		if content := readFile(t, dir, filepath.Join(skipped, file)); !strings.Contains(content, "grr.Errorf") {
+			t.Errorf("%s was converted, want it skipped:\n%s", file, content)
+		}
+	}
+}
+
+const shadowMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(errors error, other error, time int, any string) error {
+	return grr.Errorf("Join: %w and %w at %d for %s", errors, other, time, any)
+}
+`
+
+// Arguments named like the packages and predeclared identifiers the generated code refers to don't shadow them
+func TestReservedParamNames(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": shadowMain})
+
+	assertConverted(t, generate(t, dir))
+
+	if generated := readFile(t, dir, "grr.gen.go"); !strings.Contains(generated, "func NewErrJoin(errorsVal error, other error, timeVal int, anyVal string) *ErrJoin {") {
+		t.Errorf("grr.gen.go doesn't rename the reserved arguments:\n%s", generated)
+	}
+
+	if main := readFile(t, dir, "main.go"); !strings.Contains(main, "return NewErrJoin(errors, other, time, any)") {
+		t.Errorf("main.go doesn't pass the arguments as they are:\n%s", main)
+	}
+}
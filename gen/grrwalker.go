@@ -3,41 +3,74 @@ package gen
 import (
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/jackHedaya/grr/config"
 	"github.com/jackHedaya/grr/grr"
 	"github.com/jackHedaya/grr/utils"
-	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/packages"
 )
 
-var GRR_IMPORT_PATHS = []string{
-	"grr/grr",
-	"github.com/jackHedaya/grr",
-	"github.com/jackHedaya/grr/grr",
-}
+// GRR_SENTINEL_WITH_ID is the grr function generated sentinels are declared with
+var GRR_SENTINEL_WITH_ID = "SentinelWithID"
 
-var GRR_ERRORF = "Errorf"
+// GRR_SENTINEL is the grr function sentinels were declared with before they had IDs
+var GRR_SENTINEL = "Sentinel"
 
 // grrWalker is a visitor that looks for grr.Errorf calls and prints information about them.
 type grrWalker struct {
-	fset *token.FileSet
-	info *types.Info
-	pkg  *packages.Package
+	cfg       *config.Config
+	templates *Templates
+	fset      *token.FileSet
+	info      *types.Info
+	pkg       *packages.Package
 	// A map of error names to their corresponding generated error structs
 	generatedErrors map[string]GeneratedError
 	// Previous errors found in grr.gen.go files
 	prevErrors map[string]GeneratedError
 	imports    *utils.Set[string]
+	// What was found at every call site, including why those that could not be converted weren't
+	diagnostics []Diagnostic
+	// Call sites converted to errors that were already defined, positioned at the call site
+	reusedCalls []GeneratedError
+	// Byte range edits replacing the converted call sites, by file name
+	edits map[string][]textEdit
+	// The package identifiers of the converted grr.Errorf calls
+	replaced *utils.Set[*ast.Ident]
+	// The calls chained on grr.Errorf calls, innermost first, by call
+	chains map[*ast.CallExpr][]*ast.CallExpr
+	// Whether the defaults of each error can be lifted from its call sites, by error name. See sharedDefaults
+	liftable map[string]bool
+	// What go vet's printf check reports for the package, nil until the first call site is checked
+	vetDiagnostics []analysis.Diagnostic
+	// The file being walked
+	file *ast.File
+	// The name each file refers to the errors package by, for the subpackage layout
+	qualifiers map[*ast.File]string
+	// The files that need to import the errors package, with the name they import it as
+	addedImports map[*ast.File]string
 }
 
 type GeneratedError struct {
-	Name          string
+	Name string
+	// The traits and op the constructor starts the error with
+	DefaultTraits []TemplateTrait
+	DefaultOp     string
 	Args          []GrrGenErrorField
 	Msg           string
+	IsSentinel    bool
 	GeneratedCode string
+	// The checksum of the code as it was generated, written in the banner of the error so that edits by hand can be told apart.
+	// Empty for errors generated before checksums were written
+	Checksum string
+	// Where the error was found: the call site for new errors, the declaration for previous ones
+	Pos token.Position
 }
 
 // Visit implements the ast.Visitor interface for errFinder.
@@ -46,7 +79,9 @@ func (walker *grrWalker) Visit(n ast.Node) ast.Visitor {
 		return walker
 	}
 
-	grrNode, ok := getGrrNode(walker.fset, walker.info, n)
+	walker.recordChain(n)
+
+	grrNode, ok := getGrrNode(walker.cfg, walker.fset, walker.info, n)
 
 	if !ok {
 		return walker
@@ -55,33 +90,85 @@ func (walker *grrWalker) Visit(n ast.Node) ast.Visitor {
 	callExpr := grrNode.CallExpr
 
 	pos := walker.fset.Position(callExpr.Pos())
-	fmt.Printf("Found grr.Errorf call at %s:\n", pos)
 
-	args := []GrrGenErrorField{}
+	// the format is resolved through constants, so that it can be a named constant, a concatenation or a raw string
+	format, ok := constantString(walker.info, callExpr.Args[0])
+
+	if !ok {
+		walker.fail(pos, grr.Errorf("DynamicFormat: grr.Errorf format %s isn't a constant string, so its error name and message are only known at runtime", types.ExprString(callExpr.Args[0])))
+		return walker
+	}
+
+	// invalid formats would only show up as %!d(string=...) at runtime, so nothing is generated for them.
+	// names holds the fields named by placeholders like {path:%s} and //grr:name comments, by argument index
+	_, names, err := parsePlaceholders(format)
 
-	fieldGen := NewFieldGenerator(walker.fset, walker.info)
+	if err == nil {
+		err = walker.checkFormat(callExpr)
+	}
 
-	for _, arg := range callExpr.Args {
-		imp := utils.GetPackageForExpr(walker.info, arg)
+	if err != nil {
+		walker.fail(pos, err)
+		return walker
+	}
+
+	if err := addNameComments(walker.fset, walker.file, callExpr.Args[1:], names); err != nil {
+		walker.fail(pos, err)
+		return walker
+	}
+
+	// the errors package of the subpackage layout refers to every type by its package
+	var fieldPkg *types.Package
+
+	if walker.cfg.Layout != config.LayoutSubpackage {
+		fieldPkg = walker.pkg.Types
+	}
+
+	fieldGen := NewFieldGenerator(walker.fset, walker.info, fieldPkg)
+
+	for _, name := range names {
+		fieldGen.Reserve(name)
+	}
+
+	args := []GrrGenErrorField{}
 
-		if imp != nil {
-			walker.imports.Add(imp.Path())
+	for idx, arg := range callExpr.Args[1:] {
+		if name, ok := names[idx]; ok {
+			args = append(args, fieldGen.GenerateNamedField(arg, name))
+		} else {
+			args = append(args, fieldGen.GenerateField(arg))
 		}
+	}
 
-		args = append(args, fieldGen.GenerateField(arg))
+	if utils.Contains(fieldGen.Imports(), walker.pkg.PkgPath) {
+		walker.fail(pos, grr.Errorf("ImportCycle: an argument's type is declared in %s, which its errors package can't import", walker.pkg.PkgPath))
+		return walker
 	}
 
-	// pop the first argument, which is the format string
-	msg := args[0].Expr
-	args = args[1:]
+	walker.imports.AddMulti(fieldGen.Imports()...)
 
 	walker.imports.Add(grrNode.PkgImportPath)
 
+	chain := walker.chains[callExpr]
+
+	// sentinels can't have defaults, and custom templates that don't render them would drop the lifted calls
+	lifted := []*ast.CallExpr{}
+	defaultTraits := []TemplateTrait{}
+	defaultOp := ""
+
+	if name, ok := walker.errorName(format); ok && len(args) > 0 && walker.liftable[name] && walker.templates.rendersDefaults() {
+		lifted, defaultTraits, defaultOp = walker.liftDefaults(chain)
+	}
+
 	// generate the error function
 	genErr, err := walker.GenerateErrorStruct(
 		GenerateFileArgs{
-			Args:   args,
-			ErrMsg: msg,
+			Args:          args,
+			ErrMsg:        format,
+			Pos:           pos,
+			Traits:        chainTraits(chain),
+			DefaultTraits: defaultTraits,
+			DefaultOp:     defaultOp,
 		},
 	)
 
@@ -91,32 +178,176 @@ func (walker *grrWalker) Visit(n ast.Node) ast.Visitor {
 	// }
 
 	if err != nil {
-		grr.Errorf("FailedToGenerateStruct: failed to generate error struct").AddError(err).Trace()
-		return nil
+		walker.fail(pos, err)
+		return walker
+	}
+
+	// GenerateErrorStruct only returns errors that are already defined when they are identical, and the call site is
+	// rewritten to use them. Their defaults only replace the lifted calls when they are the same
+	_, isPrev := walker.prevErrors[genErr.Name]
+	_, isGenerated := walker.generatedErrors[genErr.Name]
+	isReused := isPrev || isGenerated
+
+	if isReused && (!slices.Equal(genErr.DefaultTraits, defaultTraits) || genErr.DefaultOp != defaultOp) {
+		lifted = nil
 	}
 
-	newFuncIdent := ast.NewIdent(genErr.Name)
+	// replace the grr.Errorf call with the generated error. Sentinels are used as is, and structs are built through
+	// their constructor, which takes the arguments that followed the format string exactly as they were written
+	qualifier := ""
+
+	if walker.cfg.Layout == config.LayoutSubpackage {
+		qualifier = walker.errorsQualifier() + "."
+	}
 
-	newCallExpr := &ast.CallExpr{
-		Fun:  newFuncIdent,
-		Args: callExpr.Args,
+	if genErr.IsSentinel {
+		walker.addEdit(callExpr, callExpr.Pos(), callExpr.End(), qualifier+genErr.Name)
+	} else {
+		walker.addEdit(callExpr, callExpr.Pos(), callExpr.Args[1].Pos(), qualifier+"New"+genErr.Name+"(")
 	}
 
-	// replace the grr.Errorf call with the generated error struct
-	astutil.Apply(n, func(cursor *astutil.Cursor) bool {
-		if cursor.Node() == callExpr {
-			cursor.Replace(newCallExpr)
+	// explicit ops, including the default op of the error, win over the one of the enclosing function
+	addOp := ""
+
+	if walker.cfg.AutoOp && !chainAddsOp(chain) && genErr.DefaultOp == "" {
+		if op := walker.enclosingOp(callExpr); op != "" {
+			addOp = ".AddOp(" + strconv.Quote(op) + ")"
 		}
+	}
 
-		return true
-	}, nil)
+	// the lifted calls directly follow the grr.Errorf call
+	if len(lifted) > 0 {
+		walker.addEdit(callExpr, callExpr.End(), lifted[len(lifted)-1].End(), addOp)
+
+		for _, liftedCall := range lifted {
+			walker.markReplaced(liftedCall.Args...)
+		}
+	} else if addOp != "" {
+		walker.addEdit(callExpr, callExpr.End(), callExpr.End(), addOp)
+	}
 
-	walker.generatedErrors[genErr.Name] = *genErr
+	walker.replaced.Add(grrNode.Ident)
+
+	genErr.Pos = pos
+
+	if isReused {
+		walker.reusedCalls = append(walker.reusedCalls, *genErr)
+		walker.diagnostics = append(walker.diagnostics, newInfo(pos, "Reused", "grr.Errorf call converts to the existing %s", genErr.Name))
+	} else {
+		walker.generatedErrors[genErr.Name] = *genErr
+		walker.diagnostics = append(walker.diagnostics, newInfo(pos, "Converted", "grr.Errorf call converts to %s", genErr.Name))
+
+		for idx, arg := range args {
+			if taken, ok := fieldGen.Fallback(arg.Name); ok {
+				walker.diagnostics = append(walker.diagnostics, newInfo(pos, "NameCollision",
+					"argument #%d of %s is named %s since %s is taken, name it with a trailing //grr:name comment", idx+1, genErr.Name, arg.Name, taken))
+			}
+		}
+	}
 
 	return walker
 }
 
-func getGrrNode(fset *token.FileSet, typesInfo *types.Info, node ast.Node) (*GrrNode, bool) {
+// fail records that the call at pos could not be converted. The calls nested in its arguments are still visited
+func (walker *grrWalker) fail(pos token.Position, err error) {
+	walker.diagnostics = append(walker.diagnostics, newDiagnostic(pos, err))
+}
+
+// constantString returns the value of a constant string expression
+func constantString(info *types.Info, expr ast.Expr) (string, bool) {
+	tv, ok := info.Types[expr]
+
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(tv.Value), true
+}
+
+// errorsQualifier returns the name the file being walked refers to the errors package by.
+// Files that don't import it yet get a name nothing in the file or package uses, and the import is added along with their edits
+func (walker *grrWalker) errorsQualifier() string {
+	if name, ok := walker.qualifiers[walker.file]; ok {
+		return name
+	}
+
+	pkgName, pkgPath := errorsPackage(walker.pkg, walker.cfg)
+
+	for _, spec := range walker.file.Imports {
+		if strings.Trim(spec.Path.Value, "\"") != pkgPath {
+			continue
+		}
+
+		if imported := walker.info.PkgNameOf(spec); imported != nil {
+			walker.qualifiers[walker.file] = imported.Name()
+			return imported.Name()
+		}
+	}
+
+	name := pkgName
+
+	for idx := 2; walker.isNameTaken(name); idx++ {
+		name = fmt.Sprintf("%s%d", pkgName, idx)
+	}
+
+	walker.qualifiers[walker.file] = name
+	walker.addedImports[walker.file] = name
+
+	return name
+}
+
+// isNameTaken reports whether name is declared in the package, or anywhere in the file being walked
+func (walker *grrWalker) isNameTaken(name string) bool {
+	if walker.pkg.Types.Scope().Lookup(name) != nil {
+		return true
+	}
+
+	if scope := walker.info.Scopes[walker.file]; scope != nil && scope.Lookup(name) != nil {
+		return true
+	}
+
+	for ident := range walker.info.Defs {
+		if ident.Name == name && ident.Pos() >= walker.file.Pos() && ident.Pos() < walker.file.End() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordChain records the calls chained on a grr.Errorf call when n is the outermost call of the chain,
+// e.g. grr.Errorf(...).AddTrait(TrCode, 404).AddOp("op"). The chain is visited before the grr.Errorf call it ends with
+func (walker *grrWalker) recordChain(n ast.Node) {
+	chain := []*ast.CallExpr{}
+
+	for {
+		callExpr, ok := n.(*ast.CallExpr)
+
+		if !ok {
+			return
+		}
+
+		if _, ok := getGrrNode(walker.cfg, walker.fset, walker.info, callExpr); ok {
+			// the calls further in the chain were already recorded along with the outermost one
+			if _, ok := walker.chains[callExpr]; !ok {
+				walker.chains[callExpr] = chain
+			}
+
+			return
+		}
+
+		selExpr, ok := callExpr.Fun.(*ast.SelectorExpr)
+
+		if !ok {
+			return
+		}
+
+		chain = append([]*ast.CallExpr{callExpr}, chain...)
+		n = selExpr.X
+	}
+}
+
+func getGrrNode(cfg *config.Config, fset *token.FileSet, typesInfo *types.Info, node ast.Node) (*GrrNode, bool) {
 	if node == nil {
 		return nil, false
 	}
@@ -132,12 +363,12 @@ func getGrrNode(fset *token.FileSet, typesInfo *types.Info, node ast.Node) (*Grr
 	}
 
 	ident, ok := selExpr.X.(*ast.Ident)
-	if !ok || selExpr.Sel.Name != GRR_ERRORF {
+	if !ok || selExpr.Sel.Name != cfg.Errorf {
 		return nil, false
 	}
 
 	pkg := utils.GetPackageForExpr(typesInfo, ident)
-	if pkg == nil || !utils.Contains(GRR_IMPORT_PATHS, pkg.Path()) {
+	if pkg == nil || !utils.Contains(cfg.ImportPaths, pkg.Path()) {
 		return nil, false
 	}
 
@@ -0,0 +1,136 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+)
+
+const formatsMain = "package main\n\n" + `import "github.com/jackHedaya/grr/grr"
+
+const notFound = "NotFound: %s was not found"
+
+const detail = "%d bytes"
+
+func main() {}
+
+func Named(p string) error {
+	return grr.Errorf(notFound, p)
+}
+
+func Concatenated(n int) error {
+	return grr.Errorf("ReadFailed: read " + detail, n)
+}
+
+func Raw(p string) error {
+	return grr.Errorf(` + "`Quoted: \"%s\" has a \\ in it`" + `, p)
+}
+
+func Escaped(p string) error {
+	return grr.Errorf("Tabbed: %s\tand \"quoted\"", p)
+}
+
+func Dynamic(format string, p string) error {
+	return grr.Errorf(format, p)
+}
+`
+
+// Formats are resolved through constants, so named constants, concatenations and raw strings convert, and dynamic ones are reported
+func TestFormats(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": formatsMain})
+
+	dynamic := 0
+
+	for _, diagnostic := range generate(t, dir) {
+		if diagnostic.Code == "DynamicFormat" {
+			dynamic++
+		} else if diagnostic.Severity != SeverityInfo {
+			t.Errorf("unexpected diagnostic %s", diagnostic)
+		}
+	}
+
+	if dynamic != 1 {
+		t.Errorf("got %d DynamicFormat diagnostics, want 1", dynamic)
+	}
+
+	main := readFile(t, dir, "main.go")
+
+	for _, want := range []string{"return NewErrNotFound(p)", "return NewErrReadFailed(n)", "return NewErrQuoted(p)", "return NewErrTabbed(p)", "return grr.Errorf(format, p)"} {
+		if !strings.Contains(main, want) {
+			t.Errorf("main.go doesn't contain %s:\n%s", want, main)
+		}
+	}
+
+	generated := readFile(t, dir, "grr.gen.go")
+
+	for _, want := range []string{
+		`fmt.Sprintf("%s was not found", e.p)`,
+		`fmt.Sprintf("read %d bytes", e.n)`,
+		`fmt.Sprintf("\"%s\" has a \\ in it", e.p)`,
+		`fmt.Sprintf("%s\tand \"quoted\"", e.p)`,
+	} {
+		if !strings.Contains(generated, want) {
+			t.Errorf("grr.gen.go doesn't contain %s:\n%s", want, generated)
+		}
+	}
+}
+
+const reuseMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(p string) error {
+	return grr.Errorf("NotFound: %s was not found", p)
+}
+
+func B(key string) error {
+	return grr.Errorf("NotFound: %s was not found", key)
+}
+`
+
+// Call sites identical to an error generated in the same run or an earlier one reuse its constructor, and different
+// ones are reported with both positions
+func TestReuse(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": reuseMain})
+
+	codes := []string{}
+
+	for _, diagnostic := range generate(t, dir) {
+		codes = append(codes, diagnostic.Code)
+	}
+
+	if strings.Join(codes, " ") != "Converted Reused" {
+		t.Errorf("got diagnostics %v, want Converted and Reused", codes)
+	}
+
+	main := readFile(t, dir, "main.go")
+
+	for _, want := range []string{"return NewErrNotFound(p)", "return NewErrNotFound(key)"} {
+		if !strings.Contains(main, want) {
+			t.Errorf("main.go doesn't contain %s:\n%s", want, main)
+		}
+	}
+
+	later := "package main\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nfunc C(name string) error {\n\treturn grr.Errorf(\"NotFound: %s was not found\", name)\n}\n"
+	conflict := "package main\n\nimport \"github.com/jackHedaya/grr/grr\"\n\nfunc D(n int) error {\n\treturn grr.Errorf(\"NotFound: %d was not found\", n)\n}\n"
+	writeFiles(t, dir, map[string]string{"c.go": later, "d.go": conflict})
+
+	diagnostics := generate(t, dir)
+
+	if len(diagnostics) != 2 || diagnostics[0].Code != "Reused" || diagnostics[1].Code != "Conflict" {
+		t.Fatalf("got diagnostics %v, want c.go reused and d.go conflicting", diagnostics)
+	}
+
+	if !strings.Contains(diagnostics[1].Message, "d.go:6:9") || !strings.Contains(diagnostics[1].Message, "main.go:") {
+		t.Errorf("conflict %q doesn't name both call sites", diagnostics[1].Message)
+	}
+
+	if got := readFile(t, dir, "c.go"); !strings.Contains(got, "return NewErrNotFound(name)") {
+		t.Errorf("c.go doesn't reuse ErrNotFound:\n%s", got)
+	}
+
+	if got := readFile(t, dir, "d.go"); got != conflict {
+		t.Errorf("d.go was rewritten:\n%s", got)
+	}
+}
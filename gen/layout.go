@@ -0,0 +1,191 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/grr"
+	"github.com/jackHedaya/grr/utils"
+	"golang.org/x/tools/go/packages"
+)
+
+// errorsPackage returns the name and import path of the package the errors of pkg are generated into
+func errorsPackage(pkg *packages.Package, cfg *config.Config) (string, string) {
+	if cfg.Layout != config.LayoutSubpackage {
+		return pkg.Name, pkg.PkgPath
+	}
+
+	return filepath.Base(cfg.ErrorsPackage), pkg.PkgPath + "/" + filepath.ToSlash(filepath.Clean(cfg.ErrorsPackage))
+}
+
+// generatedFiles returns the files the errors of pkg are currently generated in.
+// In the package and file layouts those are the generated files of either layout, so that switching between them moves the errors over
+func generatedFiles(pkg *packages.Package, cfg *config.Config) ([]string, error) {
+	if cfg.Layout != config.LayoutSubpackage {
+		paths := []string{}
+
+		for _, path := range pkg.GoFiles {
+			if cfg.IsGenerated(path) {
+				paths = append(paths, path)
+			}
+		}
+
+		return paths, nil
+	}
+
+	if len(pkg.GoFiles) == 0 {
+		return nil, nil
+	}
+
+	pkgPath, err := utils.GetPackagePath(pkg)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToGetPackagePath: failed to get package path").AddError(err)
+	}
+
+	path := filepath.Join(pkgPath, cfg.ErrorsPackage, cfg.OutputFile)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, grr.Errorf("FailedToStat: failed to stat %s", path).AddError(err)
+	}
+
+	return []string{path}, nil
+}
+
+// outputLayout decides which generated file every error of a package belongs in
+type outputLayout struct {
+	cfg *config.Config
+	// The directory generated files go in, except for the file layout
+	dir string
+	// The first source file using each previous error, for the file layout
+	firstUses map[string]string
+}
+
+func newOutputLayout(pkg *packages.Package, cfg *config.Config) (*outputLayout, error) {
+	pkgPath, err := utils.GetPackagePath(pkg)
+
+	if err != nil {
+		return nil, grr.Errorf("FailedToGetPackagePath: failed to get package path").AddError(err)
+	}
+
+	layout := &outputLayout{cfg: cfg, dir: pkgPath, firstUses: map[string]string{}}
+
+	if cfg.Layout == config.LayoutSubpackage {
+		layout.dir = filepath.Join(pkgPath, cfg.ErrorsPackage)
+	}
+
+	if cfg.Layout != config.LayoutFile {
+		return layout, nil
+	}
+
+	for ident, obj := range pkg.TypesInfo.Uses {
+		if obj.Pkg() != pkg.Types || obj.Parent() != pkg.Types.Scope() {
+			continue
+		}
+
+		file := pkg.Fset.Position(ident.Pos()).Filename
+
+		if cfg.IsGenerated(file) {
+			continue
+		}
+
+		for _, name := range []string{obj.Name(), strings.TrimPrefix(obj.Name(), "New")} {
+			if first, ok := layout.firstUses[name]; !ok || file < first {
+				layout.firstUses[name] = file
+			}
+		}
+	}
+
+	return layout, nil
+}
+
+// outputFile returns the generated file an error belongs in.
+// In the file layout new errors go next to their call site, and previous errors stay where they are
+// unless they come from the package layout, in which case they move next to the first file using them
+func (layout *outputLayout) outputFile(genErr GeneratedError) string {
+	if layout.cfg.Layout != config.LayoutFile {
+		return filepath.Join(layout.dir, layout.cfg.OutputFile)
+	}
+
+	file := genErr.Pos.Filename
+
+	if !layout.cfg.IsGenerated(file) {
+		return layout.cfg.FileOutput(file)
+	}
+
+	if filepath.Base(file) != layout.cfg.OutputFile {
+		return file
+	}
+
+	if first, ok := layout.firstUses[genErr.Name]; ok {
+		return layout.cfg.FileOutput(first)
+	}
+
+	return file
+}
+
+// isMisplaced reports whether any previous error isn't in the file it belongs in, e.g. after the layout changed
+func (layout *outputLayout) isMisplaced(prevErrors map[string]GeneratedError) bool {
+	for _, prevErr := range prevErrors {
+		if layout.outputFile(prevErr) != prevErr.Pos.Filename {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renderOutputs renders the generated files of a package from all of its errors.
+// Generated files left without errors are removed, and files whose content doesn't change are left out
+func renderOutputs(tmpls *Templates, pkg *packages.Package, cfg *config.Config, layout *outputLayout, errors map[string]GeneratedError, imports []string) ([]fileChange, error) {
+	pkgName, pkgPath := errorsPackage(pkg, cfg)
+
+	outputs := map[string]map[string]GeneratedError{}
+
+	for name, genErr := range errors {
+		path := layout.outputFile(genErr)
+
+		if outputs[path] == nil {
+			outputs[path] = map[string]GeneratedError{}
+		}
+
+		outputs[path][name] = genErr
+	}
+
+	existing, err := generatedFiles(pkg, cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	paths := utils.NewSetFromSlice(append(existing, utils.Keys(outputs)...)).ToSlice()
+	slices.Sort(paths)
+
+	changes := []fileChange{}
+
+	for _, path := range paths {
+		if len(outputs[path]) == 0 {
+			changes = append(changes, fileChange{path: path, remove: true})
+			continue
+		}
+
+		code, err := GenerateErrorFile(tmpls, pkgName, pkgPath, imports, outputs[path])
+
+		if err != nil {
+			return nil, grr.Errorf("GenerateErrorFile: failed to generate error file").AddError(err)
+		}
+
+		if changed, err := hasChanged(path, code); err != nil {
+			return nil, err
+		} else if changed {
+			changes = append(changes, fileChange{path: path, content: code})
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,157 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/config"
+)
+
+const layoutMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(p string) error {
+	return grr.Errorf("NotFound: %s was not found", p)
+}
+`
+
+const layoutOther = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func B() error {
+	return grr.Errorf("Timeout: timed out")
+}
+`
+
+// layoutConfig returns the default config of dir with layout
+func layoutConfig(dir string, layout string) *config.Config {
+	cfg := config.Default()
+	cfg.Root = dir
+	cfg.Layout = layout
+
+	return cfg
+}
+
+// assertDeclares fails the test unless the generated file at name declares exactly the errors of names
+func assertDeclares(t *testing.T, dir string, name string, names ...string) {
+	t.Helper()
+
+	generated := readFile(t, dir, name)
+
+	if count := strings.Count(generated, "// # checksum: "); count != len(names) {
+		t.Errorf("%s declares %d errors, want %v:\n%s", name, count, names, generated)
+	}
+
+	for _, errName := range names {
+		if !strings.Contains(generated, "// # "+errName+"\n") {
+			t.Errorf("%s doesn't declare %s:\n%s", name, errName, generated)
+		}
+	}
+}
+
+// assertMissing fails the test if any of the files of names exists in dir
+func assertMissing(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s exists, want it removed", name)
+		}
+	}
+}
+
+func TestPackageLayout(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": layoutMain, "other.go": layoutOther})
+
+	assertConverted(t, generateWith(t, dir, layoutConfig(dir, config.LayoutPackage)))
+
+	assertDeclares(t, dir, "grr.gen.go", "ErrNotFound", "ErrTimeout")
+	assertMissing(t, dir, "main_grr.gen.go", "other_grr.gen.go")
+}
+
+// The file layout declares errors next to their call sites, and moves those of the package layout there
+func TestFileLayout(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": layoutMain, "other.go": layoutOther})
+
+	assertConverted(t, generateWith(t, dir, layoutConfig(dir, config.LayoutFile)))
+
+	assertDeclares(t, dir, "main_grr.gen.go", "ErrNotFound")
+	assertDeclares(t, dir, "other_grr.gen.go", "ErrTimeout")
+	assertMissing(t, dir, "grr.gen.go")
+
+	dir = writeModule(t, map[string]string{"main.go": layoutMain, "other.go": layoutOther})
+
+	assertConverted(t, generateWith(t, dir, layoutConfig(dir, config.LayoutPackage)))
+	assertConverted(t, generateWith(t, dir, layoutConfig(dir, config.LayoutFile)))
+
+	assertDeclares(t, dir, "main_grr.gen.go", "ErrNotFound")
+	assertDeclares(t, dir, "other_grr.gen.go", "ErrTimeout")
+	assertMissing(t, dir, "grr.gen.go")
+}
+
+func TestSubpackageLayout(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": layoutMain, "other.go": layoutOther})
+
+	assertConverted(t, generateWith(t, dir, layoutConfig(dir, config.LayoutSubpackage)))
+
+	assertDeclares(t, dir, "internal/errs/grr.gen.go", "ErrNotFound", "ErrTimeout")
+	assertMissing(t, dir, "grr.gen.go")
+
+	if generated := readFile(t, dir, "internal/errs/grr.gen.go"); !strings.HasPrefix(generated, "package errs\n") {
+		t.Errorf("internal/errs/grr.gen.go isn't in package errs:\n%s", generated)
+	}
+
+	main := readFile(t, dir, "main.go")
+
+	if !strings.Contains(main, `import "example.com/proj/internal/errs"`) || !strings.Contains(main, "return errs.NewErrNotFound(p)") {
+		t.Errorf("main.go doesn't use the errors package:\n%s", main)
+	}
+}
+
+const layoutCycleMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+type User struct{ Name string }
+
+func main() {}
+
+func A(u User) error {
+	return grr.Errorf("InvalidUser: %v is not valid", u)
+}
+
+func B() error {
+	return grr.Errorf("Timeout: timed out")
+}
+`
+
+// An argument whose type the errors package would have to import from the package is reported, and the others are converted
+func TestSubpackageLayoutImportCycle(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": layoutCycleMain})
+
+	diagnostics := generateWith(t, dir, layoutConfig(dir, config.LayoutSubpackage))
+
+	cycles := 0
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == "ImportCycle" {
+			cycles++
+		}
+	}
+
+	if cycles != 1 {
+		t.Errorf("got diagnostics %v, want one ImportCycle", diagnostics)
+	}
+
+	assertDeclares(t, dir, "internal/errs/grr.gen.go", "ErrTimeout")
+
+	if main := readFile(t, dir, "main.go"); !strings.Contains(main, `grr.Errorf("InvalidUser: %v is not valid", u)`) {
+		t.Errorf("main.go converted the call site with the cycle:\n%s", main)
+	}
+}
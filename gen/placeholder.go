@@ -0,0 +1,134 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jackHedaya/grr/grr"
+	"github.com/jackHedaya/grr/utils"
+)
+
+// reservedFieldNames can't name placeholders: the intrinsic fields and the methods of generated errors
+var reservedFieldNames = append(slices.Clone(intrinsicFields),
+	"Error", "Unwrap", "UnwrapAll", "Is", "AsGrr", "AddTrait", "GetTrait", "GetTraits",
+	"AddOp", "GetOp", "AddError", "CreatedAt", "LogValue", "MarshalJSON", "Trace", "Strace",
+)
+
+// nameCommentPattern matches a //grr:name comment, which overrides the field name of the argument it trails
+var nameCommentPattern = regexp.MustCompile(`^//\s*grr:name\s+(\S+)\s*$`)
+
+// parsePlaceholders replaces the named placeholders of a format string with their verbs, like grr.StripPlaceholders,
+// and returns the name of every argument a placeholder formats, by argument index
+func parsePlaceholders(format string) (string, map[int]string, error) {
+	var stripped strings.Builder
+
+	// the placeholder names by the offset of their verb in the stripped format
+	offsets := map[int]string{}
+	last := 0
+
+	for _, match := range grr.PlaceholderPattern.FindAllStringSubmatchIndex(format, -1) {
+		placeholder := format[match[0]:match[1]]
+		name := format[match[2]:match[3]]
+		verb := format[match[4]:match[5]]
+
+		verbs, _ := parseFormat(verb)
+
+		if len(verbs) != 1 || verbs[0].Arg < 0 || verbs[0].End != len(verb) {
+			return "", nil, grr.Errorf("InvalidPlaceholder: placeholder %s must wrap exactly one verb formatting an argument", placeholder)
+		}
+
+		if utils.Contains(reservedFieldNames, name) || isReservedName(name) {
+			return "", nil, grr.Errorf("InvalidPlaceholder: placeholder %s can't be named %s, which the generated error already uses", placeholder, name)
+		}
+
+		stripped.WriteString(format[last:match[0]])
+		offsets[stripped.Len()] = name
+		stripped.WriteString(verb)
+		last = match[1]
+	}
+
+	stripped.WriteString(format[last:])
+
+	names := map[int]string{}
+	verbs, _ := parseFormat(stripped.String())
+
+	for _, verb := range verbs {
+		name, ok := offsets[verb.Start]
+
+		if !ok {
+			continue
+		}
+
+		if prev, ok := names[verb.Arg]; ok && prev != name {
+			return "", nil, grr.Errorf("InvalidPlaceholder: argument #%d is named both %s and %s", verb.Arg+1, prev, name)
+		}
+
+		for idx, other := range names {
+			if other == name && idx != verb.Arg {
+				return "", nil, grr.Errorf("InvalidPlaceholder: placeholder name %s is used for arguments #%d and #%d", name, idx+1, verb.Arg+1)
+			}
+		}
+
+		names[verb.Arg] = name
+	}
+
+	return stripped.String(), names, nil
+}
+
+// addNameComments adds the field names //grr:name comments give to args to names, which holds the names of the placeholders.
+// A comment names the last argument ending on its line before it
+func addNameComments(fset *token.FileSet, file *ast.File, args []ast.Expr, names map[int]string) error {
+	if file == nil || len(args) == 0 {
+		return nil
+	}
+
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			match := nameCommentPattern.FindStringSubmatch(comment.Text)
+
+			if match == nil || comment.Pos() < args[0].Pos() {
+				continue
+			}
+
+			line := fset.Position(comment.Pos()).Line
+			named := -1
+
+			for idx, arg := range args {
+				if arg.End() <= comment.Pos() && fset.Position(arg.End()).Line == line {
+					named = idx
+				}
+			}
+
+			if named < 0 {
+				continue
+			}
+
+			name := match[1]
+
+			if !token.IsIdentifier(name) {
+				return grr.Errorf("InvalidFieldName: //grr:name %s is not a valid Go identifier", name)
+			}
+
+			if utils.Contains(reservedFieldNames, name) || isReservedName(name) {
+				return grr.Errorf("InvalidFieldName: argument #%d can't be named %s, which the generated error already uses", named+1, name)
+			}
+
+			if prev, ok := names[named]; ok && prev != name {
+				return grr.Errorf("InvalidFieldName: argument #%d is named both %s and %s", named+1, prev, name)
+			}
+
+			for idx, other := range names {
+				if other == name && idx != named {
+					return grr.Errorf("InvalidFieldName: field name %s is used for arguments #%d and #%d", name, idx+1, named+1)
+				}
+			}
+
+			names[named] = name
+		}
+	}
+
+	return nil
+}
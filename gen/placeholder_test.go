@@ -0,0 +1,105 @@
+package gen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+func TestParsePlaceholders(t *testing.T) {
+	tests := []struct {
+		format    string
+		stripped  string
+		names     map[int]string
+		wantError string
+	}{
+		{format: "file %s not found", stripped: "file %s not found", names: map[int]string{}},
+		{format: "file {path:%s} not found", stripped: "file %s not found", names: map[int]string{0: "path"}},
+		{format: "{count:%d} of %d by {user:%v}", stripped: "%d of %d by %v", names: map[int]string{0: "count", 2: "user"}},
+		{format: "{total:%[2]d} then {first:%[1]s}", stripped: "%[2]d then %[1]s", names: map[int]string{0: "first", 1: "total"}},
+		{format: "{path:%s} again {path:%[1]q}", stripped: "%s again %[1]q", names: map[int]string{0: "path"}},
+		{format: "100%% {done:%t}", stripped: "100%% %t", names: map[int]string{0: "done"}},
+		{format: "literal {braces} and {1:%s}", stripped: "literal {braces} and {1:%s}", names: map[int]string{}},
+		{format: "{count:%*d}", stripped: "%*d", names: map[int]string{1: "count"}},
+		{format: "{pct:%%}", wantError: "InvalidPlaceholder"},
+		{format: "{path:%s} and {other:%[1]s}", wantError: "InvalidPlaceholder"},
+		{format: "{path:%s} and {path:%s}", wantError: "InvalidPlaceholder"},
+	}
+
+	for _, test := range tests {
+		stripped, names, err := parsePlaceholders(test.format)
+
+		if grr.ID(err) != test.wantError {
+			t.Errorf("parsePlaceholders(%q) error = %v, want %q", test.format, err, test.wantError)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if stripped != test.stripped || !reflect.DeepEqual(names, test.names) {
+			t.Errorf("parsePlaceholders(%q) = %q, %v, want %q, %v", test.format, stripped, names, test.stripped, test.names)
+		}
+
+		if stripped != grr.StripPlaceholders(test.format) {
+			t.Errorf("parsePlaceholders(%q) strips to %q, but grr.StripPlaceholders to %q", test.format, stripped, grr.StripPlaceholders(test.format))
+		}
+	}
+}
+
+func TestParsePlaceholdersReservedNames(t *testing.T) {
+	for _, name := range []string{"err", "op", "traits", "created", "Error", "Unwrap", "AddTrait", "errors", "fmt", "grr", "slog", "time", "any", "string", "nil", "len", "func"} {
+		if _, _, err := parsePlaceholders("failed {" + name + ":%v}"); grr.ID(err) != "InvalidPlaceholder" {
+			t.Errorf("placeholder named %s: got error %v, want InvalidPlaceholder", name, err)
+		}
+	}
+}
+
+const placeholderMain = `package main
+
+import "github.com/jackHedaya/grr/grr"
+
+func main() {}
+
+func A(p string, n int) error {
+	return grr.Errorf("ReadFailed: read {size:%d} bytes of {path:%q}", n, p)
+}
+
+func B(p string) error {
+	return grr.Errorf("Reserved: {errors:%s}", p)
+}
+`
+
+// Placeholders name the fields of the generated error and are stripped from its message, and reserved names are reported
+func TestPlaceholders(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": placeholderMain})
+
+	invalid := 0
+
+	for _, diagnostic := range generate(t, dir) {
+		if diagnostic.Code == "InvalidPlaceholder" {
+			invalid++
+		} else if diagnostic.Severity != SeverityInfo {
+			t.Errorf("unexpected diagnostic %s", diagnostic)
+		}
+	}
+
+	if invalid != 1 {
+		t.Errorf("got %d InvalidPlaceholder diagnostics, want 1", invalid)
+	}
+
+	generated := readFile(t, dir, "grr.gen.go")
+
+	for _, want := range []string{"func NewErrReadFailed(size int, path string) *ErrReadFailed {", `fmt.Sprintf("read %d bytes of %q", e.size, e.path)`} {
+		if !strings.Contains(generated, want) {
+			t.Errorf("grr.gen.go doesn't contain %s:\n%s", want, generated)
+		}
+	}
+
+	if strings.Contains(generated, "ErrReserved") {
+		t.Errorf("grr.gen.go declares the error with a reserved placeholder:\n%s", generated)
+	}
+}
@@ -2,69 +2,184 @@ package gen
 
 import (
 	"go/ast"
+	"go/parser"
 	"go/token"
 	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/jackHedaya/grr/config"
 	"github.com/jackHedaya/grr/grr"
+	"github.com/jackHedaya/grr/utils"
 	"golang.org/x/tools/go/packages"
 )
 
-func LoadPreviousErrors(pkg string) (map[string]GeneratedError, error) {
-	pkgs, err := packages.Load(&packages.Config{
-		Mode: packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
-	}, pkg)
+// Fields every generated struct has that don't come from the format arguments
+var intrinsicFields = []string{"err", "op", "traits", "created"}
+
+// LoadPreviousErrors finds the errors already generated for the package along with the imports of their files.
+// The code of each error is kept verbatim so that regenerating the file leaves it untouched
+func LoadPreviousErrors(pkg *packages.Package, cfg *config.Config) (map[string]GeneratedError, []string, error) {
+	prevErrors := map[string]GeneratedError{}
+	imports := []string{}
+
+	paths, err := generatedFiles(pkg, cfg)
 
 	if err != nil {
-		return nil, grr.Errorf("FailedToLoad: failed to load package").AddError(err)
+		return nil, nil, err
 	}
 
-	if len(pkgs) != 1 {
-		return nil, grr.Errorf("OneExpected: expected one package, got %d", len(pkgs))
-	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
 
-	p := pkgs[0]
+		if err != nil {
+			return nil, nil, grr.Errorf("FailedToRead: failed to read %s", path).AddError(err)
+		}
 
-	// Create the walker
-	walker := &prevWalker{
-		fset:       token.NewFileSet(),
-		info:       p.TypesInfo,
-		pkg:        p,
-		prevErrors: map[string]GeneratedError{},
-	}
+		walker := &prevWalker{
+			cfg:        cfg,
+			fset:       pkg.Fset,
+			src:        src,
+			prevErrors: map[string]GeneratedError{},
+			decls:      map[string][]string{},
+			params:     map[string][]string{},
+		}
+
+		file := syntaxOf(pkg, path)
+
+		if file != nil {
+			walker.info = pkg.TypesInfo
+		} else {
+			// the errors package of the subpackage layout isn't part of pkg
+			if file, err = parser.ParseFile(pkg.Fset, path, src, parser.ParseComments); err != nil {
+				return nil, nil, grr.Errorf("FailedToParse: failed to parse %s", path).AddError(err)
+			}
+		}
+
+		walker.file = file
 
-	// Walk the package
-	for _, file := range p.Syntax {
 		ast.Walk(walker, file)
+
+		for _, imp := range file.Imports {
+			imports = append(imports, strings.Trim(imp.Path.Value, "\""))
+		}
+
+		prevErrors = utils.Merge(prevErrors, walker.collect())
 	}
 
-	return walker.prevErrors, nil
+	return prevErrors, imports, nil
+}
+
+// syntaxOf returns the syntax tree of the file of pkg at path, or nil if it isn't one of its files
+func syntaxOf(pkg *packages.Package, path string) *ast.File {
+	for idx, file := range pkg.Syntax {
+		if pkg.GoFiles[idx] == path {
+			return file
+		}
+	}
+
+	return nil
 }
 
 type prevWalker struct {
+	cfg  *config.Config
 	fset *token.FileSet
+	// Type information of the file, nil when it was parsed on its own
 	info *types.Info
-	pkg  *packages.Package
+	file *ast.File
+	// The source of the grr.gen.go file being walked
+	src []byte
 	// Previous errors found in grr.gen.go files
 	prevErrors map[string]GeneratedError
+	// The source of every declaration belonging to an error, by error name
+	decls map[string][]string
+	// The parameters of the constructor of every error, by error name
+	params map[string][]string
 }
 
-// Visit implements the ast.Visitor interface for errFinder.
+// Visit implements the ast.Visitor interface for prevWalker. Only top level declarations are inspected
 func (walker *prevWalker) Visit(n ast.Node) ast.Visitor {
-	if n == nil {
+	switch decl := n.(type) {
+	case *ast.File:
+		walker.visitBanners(decl)
 		return walker
+
+	case *ast.GenDecl:
+		switch decl.Tok {
+		case token.TYPE:
+			walker.visitStructs(decl)
+		case token.VAR:
+			walker.visitVars(decl)
+		}
+
+	case *ast.FuncDecl:
+		walker.visitFunc(decl)
 	}
 
-	// We're looking for structs that are generated by grr
-	genDecl, ok := n.(*ast.GenDecl)
+	return nil
+}
 
-	if !ok {
-		return walker
+// bannerPattern matches the banner written above each error, which holds the checksum of its code
+var bannerPattern = regexp.MustCompile(`(?m)^# (\w+)\n# checksum: ([0-9a-f]+)$`)
+
+// visitBanners records the checksums written in the banners of the errors
+func (walker *prevWalker) visitBanners(file *ast.File) {
+	for _, group := range file.Comments {
+		matches := bannerPattern.FindStringSubmatch(group.Text())
+
+		if len(matches) != 3 {
+			continue
+		}
+
+		prevErr := walker.prevErrors[matches[1]]
+		prevErr.Checksum = matches[2]
+		walker.prevErrors[matches[1]] = prevErr
 	}
+}
 
-	if genDecl.Tok != token.TYPE {
-		return walker
+// collect attaches the verbatim code to every error found
+func (walker *prevWalker) collect() map[string]GeneratedError {
+	for name, prevErr := range walker.prevErrors {
+		// methods of a type that isn't declared in grr.gen.go
+		if prevErr.Name == "" {
+			delete(walker.prevErrors, name)
+			continue
+		}
+
+		prevErr.GeneratedCode = "\n" + strings.Join(walker.decls[name], "\n\n") + "\n"
+
+		// the arguments are named like the constructor parameters, which exported fields aren't
+		if params := walker.params[name]; len(params) == len(prevErr.Args) {
+			for idx := range prevErr.Args {
+				prevErr.Args[idx].Name = params[idx]
+			}
+		}
+
+		walker.prevErrors[name] = prevErr
+	}
+
+	return walker.prevErrors
+}
+
+// addDecl records the source of decl, including its doc comment, as part of the error called owner
+func (walker *prevWalker) addDecl(owner string, decl ast.Decl, doc *ast.CommentGroup) {
+	start := decl.Pos()
+
+	// the banner written above each error is not part of its code
+	if doc != nil && !strings.HasPrefix(doc.Text(), "###") {
+		start = doc.Pos()
 	}
 
+	from := walker.fset.Position(start).Offset
+	to := walker.fset.Position(decl.End()).Offset
+
+	walker.decls[owner] = append(walker.decls[owner], string(walker.src[from:to]))
+}
+
+func (walker *prevWalker) visitStructs(genDecl *ast.GenDecl) {
 	for _, spec := range genDecl.Specs {
 		typeSpec, ok := spec.(*ast.TypeSpec)
 
@@ -78,45 +193,262 @@ func (walker *prevWalker) Visit(n ast.Node) ast.Visitor {
 			continue
 		}
 
-		grrErrLookup := walker.pkg.Types.Scope().Lookup("Error")
+		// Get the name of the struct
+		name := typeSpec.Name.Name
+
+		// Get the fields of the struct
+		fields := []GrrGenErrorField{}
+
+		for _, field := range structType.Fields.List {
+			for _, fieldName := range field.Names {
+				if utils.Contains(intrinsicFields, fieldName.Name) {
+					continue
+				}
+
+				fields = append(fields, GrrGenErrorField{
+					Name:  fieldName.Name,
+					Field: fieldName.Name,
+					Type:  types.ExprString(field.Type),
+				})
+			}
+		}
+
+		prevErr := walker.prevErrors[name]
+		prevErr.Name = name
+		prevErr.Args = fields
+		prevErr.Pos = walker.fset.Position(typeSpec.Pos())
+		walker.prevErrors[name] = prevErr
+	}
+
+	walker.addDecl(genDecl.Specs[0].(*ast.TypeSpec).Name.Name, genDecl, genDecl.Doc)
+}
+
+// visitVars records vars declared as grr.SentinelWithID("Name", "message") or grr.Sentinel("Name: message") and the
+// var _ grr.Error = &ErrX{} assertions of generated structs
+func (walker *prevWalker) visitVars(genDecl *ast.GenDecl) {
+	for _, spec := range genDecl.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+
+		if !ok || len(valueSpec.Names) != 1 || len(valueSpec.Values) != 1 {
+			continue
+		}
+
+		name := valueSpec.Names[0].Name
+
+		if name == "_" {
+			if unary, ok := valueSpec.Values[0].(*ast.UnaryExpr); ok {
+				if lit, ok := unary.X.(*ast.CompositeLit); ok {
+					walker.addDecl(types.ExprString(lit.Type), genDecl, genDecl.Doc)
+				}
+			}
 
-		if grrErrLookup == nil {
 			continue
 		}
 
-		grrType := grrErrLookup.Type().Underlying().(*types.Interface)
-		comparisonType := walker.info.TypeOf(typeSpec.Name)
-		ptr := types.NewPointer(comparisonType)
+		callExpr, ok := valueSpec.Values[0].(*ast.CallExpr)
 
-		if !types.Implements(ptr, grrType) {
+		if !ok || len(callExpr.Args) == 0 {
 			continue
 		}
 
-		// Get the name of the struct
-		name := typeSpec.Name.Name
+		selExpr, ok := callExpr.Fun.(*ast.SelectorExpr)
 
-		// Get the fields of the struct
-		fields := []GrrGenErrorField{}
+		if !ok || !walker.isGrrPackage(selExpr.X) {
+			continue
+		}
 
-		for _, field := range structType.Fields.List {
-			fieldName := field.Names[0].Name
+		// sentinels are declared with their ID and message, or by older versions with the message starting with the name
+		isLegacy := selExpr.Sel.Name == GRR_SENTINEL && len(callExpr.Args) == 1
+
+		if !isLegacy && (selExpr.Sel.Name != GRR_SENTINEL_WITH_ID || len(callExpr.Args) != 2) {
+			continue
+		}
+
+		lit, ok := callExpr.Args[len(callExpr.Args)-1].(*ast.BasicLit)
 
-			fieldType := walker.info.TypeOf(field.Type).String()
+		if !ok || lit.Kind != token.STRING {
+			continue
+		}
+
+		msg, ok := literalMessage(lit)
 
-			fields = append(fields, GrrGenErrorField{
-				Name: fieldName,
-				Type: fieldType,
-			})
+		if !ok {
+			continue
 		}
 
-		// Get the generated code
+		// the message of the call site is a format, in which the sentinel's percent signs were escaped
+		msg = sentinelFormat(msg)
+
+		if matches := walker.cfg.NameRegexp().FindStringSubmatch(msg); isLegacy && len(matches) >= 3 {
+			msg = strings.TrimSpace(matches[2])
+		}
 
 		walker.prevErrors[name] = GeneratedError{
-			Name:          name,
-			Args:          fields,
-			GeneratedCode: "", // doesn't matter because prevErrors are not rewritten, only used to prevent collisions
+			Name:       name,
+			Args:       []GrrGenErrorField{},
+			Msg:        msg,
+			IsSentinel: true,
+			Checksum:   walker.prevErrors[name].Checksum,
+			Pos:        walker.fset.Position(valueSpec.Pos()),
+		}
+
+		walker.addDecl(name, genDecl, genDecl.Doc)
+	}
+}
+
+// isGrrPackage reports whether expr refers to the grr package, by its imports when there is no type information
+func (walker *prevWalker) isGrrPackage(expr ast.Expr) bool {
+	if walker.info != nil {
+		pkg := utils.GetPackageForExpr(walker.info, expr)
+
+		return pkg != nil && utils.Contains(walker.cfg.ImportPaths, pkg.Path())
+	}
+
+	ident, ok := expr.(*ast.Ident)
+
+	if !ok {
+		return false
+	}
+
+	for _, imp := range walker.file.Imports {
+		path := strings.Trim(imp.Path.Value, "\"")
+
+		if !utils.Contains(walker.cfg.ImportPaths, path) {
+			continue
+		}
+
+		name := filepath.Base(path)
+
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+
+		if name == ident.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// visitFunc assigns constructors and methods to their error, and reads the message from Error()
+func (walker *prevWalker) visitFunc(funcDecl *ast.FuncDecl) {
+	if funcDecl.Recv == nil {
+		if name, ok := strings.CutPrefix(funcDecl.Name.Name, "New"); ok {
+			walker.addDecl(name, funcDecl, funcDecl.Doc)
+
+			for _, param := range funcDecl.Type.Params.List {
+				for _, paramName := range param.Names {
+					walker.params[name] = append(walker.params[name], paramName.Name)
+				}
+			}
+
+			walker.visitConstructor(name, funcDecl)
+		}
+
+		return
+	}
+
+	if len(funcDecl.Recv.List) != 1 {
+		return
+	}
+
+	recv := funcDecl.Recv.List[0].Type
+
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+
+	owner := types.ExprString(recv)
+
+	walker.addDecl(owner, funcDecl, funcDecl.Doc)
+
+	if funcDecl.Name.Name != "Error" || funcDecl.Body == nil {
+		return
+	}
+
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		callExpr, ok := n.(*ast.CallExpr)
+
+		if !ok || len(callExpr.Args) == 0 {
+			return true
+		}
+
+		if selExpr, ok := callExpr.Fun.(*ast.SelectorExpr); !ok || (selExpr.Sel.Name != "Sprintf" && selExpr.Sel.Name != "Errorf") {
+			return true
+		}
+
+		if lit, ok := callExpr.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if msg, ok := literalMessage(lit); ok {
+				prevErr := walker.prevErrors[owner]
+				prevErr.Msg = msg
+				walker.prevErrors[owner] = prevErr
+			}
+		}
+
+		return false
+	})
+}
+
+// visitConstructor reads the defaults a constructor initializes its error with
+func (walker *prevWalker) visitConstructor(owner string, funcDecl *ast.FuncDecl) {
+	if funcDecl.Body == nil {
+		return
+	}
+
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+
+		if !ok {
+			return true
+		}
+
+		key, ok := kv.Key.(*ast.Ident)
+
+		if !ok {
+			return true
 		}
+
+		prevErr := walker.prevErrors[owner]
+
+		switch key.Name {
+		case "traits":
+			if lit, ok := kv.Value.(*ast.CompositeLit); ok {
+				prevErr.DefaultTraits = []TemplateTrait{}
+
+				for _, elt := range lit.Elts {
+					if trait, ok := elt.(*ast.KeyValueExpr); ok {
+						prevErr.DefaultTraits = append(prevErr.DefaultTraits, TemplateTrait{
+							Trait: types.ExprString(trait.Key),
+							Value: types.ExprString(trait.Value),
+						})
+					}
+				}
+			}
+
+		case "op":
+			if lit, ok := kv.Value.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				prevErr.DefaultOp, _ = strconv.Unquote(lit.Value)
+			}
+		}
+
+		walker.prevErrors[owner] = prevErr
+
+		return false
+	})
+}
+
+// literalMessage returns the message of a string literal, escaped the way the generated code writes it in double quotes
+func literalMessage(lit *ast.BasicLit) (string, bool) {
+	if lit.Kind != token.STRING {
+		return "", false
+	}
+
+	msg, err := strconv.Unquote(lit.Value)
+
+	if err != nil {
+		return "", false
 	}
 
-	return walker
+	return escapeString(msg), true
 }
@@ -0,0 +1,120 @@
+package gen
+
+import (
+	"go/ast"
+	"go/token"
+	"os/exec"
+	"testing"
+
+	"github.com/jackHedaya/grr/config"
+)
+
+func TestLiteralMessage(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    string
+	}{
+		{literal: `"%s was not found"`, want: `%s was not found`},
+		{literal: `"said \"hi\""`, want: `said \"hi\"`},
+		{literal: "`said \"hi\"`", want: `said \"hi\"`},
+		{literal: `"tab\there"`, want: `tab\there`},
+	}
+
+	for _, test := range tests {
+		got, ok := literalMessage(&ast.BasicLit{Kind: token.STRING, Value: test.literal})
+
+		if !ok || got != test.want {
+			t.Errorf("literalMessage(%s) = %q, %v, want %q", test.literal, got, ok, test.want)
+		}
+	}
+}
+
+func TestSentinelMessage(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "resource not found", want: "resource not found"},
+		{format: "100%% done", want: "100% done"},
+		{format: "%%%%", want: "%%"},
+	}
+
+	for _, test := range tests {
+		if got := sentinelMessage(test.format); got != test.want {
+			t.Errorf("sentinelMessage(%q) = %q, want %q", test.format, got, test.want)
+		}
+
+		if got := sentinelFormat(sentinelMessage(test.format)); got != test.format {
+			t.Errorf("sentinelFormat(%q) = %q, want %q", sentinelMessage(test.format), got, test.format)
+		}
+	}
+}
+
+const messagesMain = `package main
+
+import (
+	"fmt"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+func main() {
+	fmt.Println(Timeout())
+	fmt.Println(NotFound("user"))
+	fmt.Println(grr.ID(Timeout()), grr.ID(NotFound("user")))
+}
+
+func Timeout() error {
+	return grr.Errorf("Timeout: 100%% timed out")
+}
+
+func NotFound(what string) error {
+	return grr.Errorf("NotFound: %s was not found", what)
+}
+`
+
+// Generated sentinels and structs print their message without the error name, and are read back with it
+func TestSentinelAndStructMessages(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": messagesMain})
+
+	for _, diagnostic := range generate(t, dir) {
+		if diagnostic.Severity != SeverityInfo {
+			t.Errorf("unexpected diagnostic %s", diagnostic)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+
+	if want := "100% timed out\nuser was not found\nTimeout NotFound\n"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	cfg := config.Default()
+	cfg.Root = dir
+
+	pkgs, err := loadPackages(dir, cfg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevErrors, _, err := LoadPreviousErrors(pkgs[0], cfg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := prevErrors["ErrTimeout"]; !got.IsSentinel || got.Msg != "100%% timed out" {
+		t.Errorf("ErrTimeout = %+v, want a sentinel with the format 100%%%% timed out", got)
+	}
+
+	if got := prevErrors["ErrNotFound"]; got.IsSentinel || got.Msg != "%s was not found" {
+		t.Errorf("ErrNotFound = %+v, want a struct with the format %%s was not found", got)
+	}
+}
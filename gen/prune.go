@@ -0,0 +1,147 @@
+package gen
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/jackHedaya/grr/config"
+	"github.com/jackHedaya/grr/grr"
+	"github.com/jackHedaya/grr/utils"
+	"golang.org/x/tools/go/packages"
+)
+
+// PrunedError is a generated error removed by PruneEntry
+type PrunedError struct {
+	PkgPath string
+	Name    string
+}
+
+// PruneEntry removes generated errors that are no longer referenced by any package in the directory.
+// Errors of packages that other modules can import might still be used elsewhere, so they are only removed when force is set.
+// Those are reported as NotPruned diagnostics at their declaration instead
+func PruneEntry(directory string, force bool, cfg *config.Config) ([]PrunedError, []Diagnostic, error) {
+	tmpls, err := LoadTemplates(cfg)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkgs, err := loadPackages(directory, cfg)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, nil, grr.Errorf("PackageHasErrors: refusing to prune while package %s has errors: %v", pkg.PkgPath, pkg.Errors)
+		}
+	}
+
+	// errors referenced only from tests are still used
+	testPkgs, err := loadPackagesWithTests(directory, cfg)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	used := usedObjects(testPkgs, cfg)
+	pruned := []PrunedError{}
+	diagnostics := []Diagnostic{}
+	changes := []fileChange{}
+
+	for _, pkg := range pkgs {
+		prevErrors, prevImports, err := LoadPreviousErrors(pkg, cfg)
+
+		if err != nil {
+			return nil, nil, grr.Errorf("FailedToLoadPreviousErrors: failed to load previous errors").AddError(err)
+		}
+
+		unused := []string{}
+
+		_, errorsPath := errorsPackage(pkg, cfg)
+
+		for name := range prevErrors {
+			if !used.Has(errorsPath+"."+name) && !used.Has(errorsPath+".New"+name) {
+				unused = append(unused, name)
+			}
+		}
+
+		if len(unused) == 0 {
+			continue
+		}
+
+		slices.Sort(unused)
+
+		if isImportable(pkg) && !force {
+			for _, name := range unused {
+				err := grr.Errorf("NotPruned: %s is no longer used in the module, but other modules may use it", name)
+
+				diagnostics = append(diagnostics, newDiagnostic(parsePosition(prevErrors[name].Pos.String()), err))
+			}
+
+			continue
+		}
+
+		for _, name := range unused {
+			delete(prevErrors, name)
+			pruned = append(pruned, PrunedError{PkgPath: pkg.PkgPath, Name: name})
+		}
+
+		layout, err := newOutputLayout(pkg, cfg)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pkgChanges, err := renderOutputs(tmpls, pkg, cfg, layout, prevErrors, prevImports)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		changes = append(changes, pkgChanges...)
+	}
+
+	if err := commitChanges(changes); err != nil {
+		return nil, nil, grr.Errorf("FailedToWriteFile: failed to write generated files").AddError(err)
+	}
+
+	return pruned, diagnostics, nil
+}
+
+// usedObjects returns "pkgpath.Name" for every package level object referenced outside of a generated file
+func usedObjects(pkgs []*packages.Package, cfg *config.Config) *utils.Set[string] {
+	used := utils.NewSet[string]()
+
+	for _, pkg := range pkgs {
+		for ident, obj := range pkg.TypesInfo.Uses {
+			if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+				continue
+			}
+
+			if cfg.IsGenerated(pkg.Fset.Position(ident.Pos()).Filename) {
+				continue
+			}
+
+			used.Add(obj.Pkg().Path() + "." + obj.Name())
+		}
+	}
+
+	return used
+}
+
+// isImportable reports whether packages outside of the module could import pkg
+func isImportable(pkg *packages.Package) bool {
+	if pkg.Name == "main" {
+		return false
+	}
+
+	for _, part := range strings.Split(pkg.PkgPath, "/") {
+		if part == "internal" {
+			return false
+		}
+	}
+
+	return true
+}
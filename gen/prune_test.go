@@ -0,0 +1,59 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/config"
+)
+
+const pruneLib = `package proj
+
+import "github.com/jackHedaya/grr/grr"
+
+func A(p string) error {
+	return grr.Errorf("NotFound: %s was not found", p)
+}
+`
+
+// Errors of importable packages are reported instead of pruned, unless forced
+func TestPruneImportable(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		dir := writeModule(t, map[string]string{"a.go": pruneLib})
+
+		generate(t, dir)
+		writeFiles(t, dir, map[string]string{"a.go": "package proj\n"})
+
+		cfg := config.Default()
+		cfg.Root = dir
+
+		pruned, diagnostics, err := PruneEntry(dir, force, cfg)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if force {
+			if len(pruned) != 1 || pruned[0].Name != "ErrNotFound" || len(diagnostics) != 0 {
+				t.Errorf("forced prune = %v, %v, want ErrNotFound pruned", pruned, diagnostics)
+			}
+
+			// the file is removed along with its last error
+			if _, err := os.Stat(filepath.Join(dir, "grr.gen.go")); !os.IsNotExist(err) {
+				t.Errorf("grr.gen.go wasn't removed: %v", err)
+			}
+
+			continue
+		}
+
+		if len(pruned) != 0 || len(diagnostics) != 1 {
+			t.Fatalf("prune = %v, %v, want one diagnostic", pruned, diagnostics)
+		}
+
+		if d := diagnostics[0]; d.Code != "NotPruned" || d.Severity != SeverityWarning || !strings.HasSuffix(d.File, "grr.gen.go") {
+			t.Errorf("diagnostic = %s, want a NotPruned warning in grr.gen.go", d)
+		}
+	}
+}
@@ -3,16 +3,18 @@ package gen
 import (
 	"bytes"
 	_ "embed"
-	"go/format"
-	"os"
-	"regexp"
+	"go/parser"
+	"go/printer"
+	"go/token"
 	"slices"
+	"strconv"
 	"strings"
 
 	"text/template"
 
 	"github.com/jackHedaya/grr/grr"
 	"github.com/jackHedaya/grr/utils"
+	"golang.org/x/tools/go/ast/astutil"
 )
 
 //go:embed errorStruct.tmpl
@@ -21,29 +23,80 @@ var errorStructTemplateStr string
 //go:embed errorFile.tmpl
 var errorFileTemplateStr string
 
-var templateFuncs = template.FuncMap{
-	"notlast": func(index int, len int) bool {
-		return index+1 != len
-	},
-}
+//go:embed errorSentinel.tmpl
+var errorSentinelTemplateStr string
+
+var errorStructTemplate = template.Must(template.New("errorStruct.tmpl").Funcs(templateFuncs).Parse(errorStructTemplateStr))
+var errorFileTemplate = template.Must(template.New("errorFile.tmpl").Funcs(templateFuncs).Parse(errorFileTemplateStr))
+var errorSentinelTemplate = template.Must(template.New("errorSentinel.tmpl").Funcs(templateFuncs).Parse(errorSentinelTemplateStr))
 
-var errorStructTemplate = template.Must(template.New("").Funcs(templateFuncs).Parse(errorStructTemplateStr))
-var errorFileTemplate = template.Must(template.New("").Funcs(templateFuncs).Parse(errorFileTemplateStr))
+// TemplateDataVersion is the version of the data templates are executed with.
+// It is bumped whenever a field of StructTemplateData or HeaderTemplateData is removed or changes meaning,
+// so that custom templates written for another version are rejected when they are loaded rather than generating broken code
+const TemplateDataVersion = 1
 
+// GrrGenErrorField is a field of a generated error, one per argument that followed the format string
 type GrrGenErrorField struct {
+	// The argument as written at the call site
 	Expr string
+	// The name of the argument, which the constructor parameter is called
 	Name string
+	// The name of the struct field, which is exported when the config exports fields
+	Field string
+	// The name of the method returning the field, empty when the field is exported
+	Getter string
+	// The field type, qualified the way the generated file refers to it. Always error for causes
 	Type string
+	// The message wraps the field with %w
+	IsCause bool
 }
 
+// TemplateTrait is a trait chained on a call site, e.g. grr.Errorf(...).AddTrait(TrCode, 404)
+type TemplateTrait struct {
+	// The trait as written at the call site, e.g. TrCode
+	Trait string
+	// The value as written at the call site, e.g. 404
+	Value string
+}
+
+// StructTemplateData is the data the struct and sentinel templates are executed with
 type StructTemplateData struct {
+	// Always TemplateDataVersion
+	Version int
+	// The error name without the Err prefix, as written in the message
+	Name string
+	// What grr.ID returns for the error
+	ID string
+	// The name of the generated type or sentinel, e.g. ErrFileNotFound
 	ErrName string
 	Vars    []GrrGenErrorField
+	// The Vars the message wraps with %w, in order. The generated error unwraps to them, joined when there are several
+	Causes []GrrGenErrorField
+	// The format string without the error name. It may contain %w verbs, so it has to be rendered with fmt.Errorf.
+	// Sentinels aren't formatted, so theirs is the text grr.Errorf printed, with %% unescaped to %
 	Message string
+	// The package the error is generated into
+	PkgName string
+	PkgPath string
+	// The call site the error was generated from.
+	// It is zero when grr check renders an error that was generated before, so templates printing it are reported as modified
+	Pos token.Position
+	// The traits chained on the call site, empty when grr check renders an error that was generated before
+	Traits []TemplateTrait
+	// The traits and op the constructor starts the error with, lifted from the constant AddTrait and AddOp calls
+	// chained on the call site. Templates that don't render both keep those calls at the call site
+	DefaultTraits []TemplateTrait
+	DefaultOp     string
+	// The fields are exported rather than read through getters
+	ExportFields bool
 }
 
+// HeaderTemplateData is the data the file template is executed with
 type HeaderTemplateData struct {
+	// Always TemplateDataVersion
+	Version         int
 	PkgName         string
+	PkgPath         string
 	Imports         []string
 	GeneratedErrors []GeneratedError
 }
@@ -52,8 +105,15 @@ var TrIsInternal = grr.NewTrait("IsInternal")
 var TrIsNonFatal = grr.NewTrait("IsNonFatal")
 
 type GenerateFileArgs struct {
+	// The value of the format string
 	ErrMsg string
 	Args   []GrrGenErrorField
+	// The call site and the traits chained on it
+	Pos    token.Position
+	Traits []TemplateTrait
+	// The defaults lifted from the call site
+	DefaultTraits []TemplateTrait
+	DefaultOp     string
 }
 
 func (f *grrWalker) GenerateErrorStruct(params GenerateFileArgs) (*GeneratedError, error) {
@@ -62,64 +122,84 @@ func (f *grrWalker) GenerateErrorStruct(params GenerateFileArgs) (*GeneratedErro
 	errMsg := params.ErrMsg
 	args := params.Args
 
-	if len(errMsg) == 0 || errMsg == "\"\"" {
+	if len(errMsg) == 0 {
 		return nil, grr.Errorf("NoErrorMessage: error message not found").
 			AddOp(op)
 	}
 
-	if errMsg[0] == '"' {
-		errMsg = errMsg[1:]
-	}
-
-	if errMsg[len(errMsg)-1] == '"' {
-		errMsg = errMsg[:len(errMsg)-1]
-	}
+	// the message is matched and kept escaped, the way it is written in the double quoted strings of the generated code
+	errMsg = escapeString(errMsg)
 
 	// extract the new error name from message
 	// For example, "FileNotFound: a file with name %s was not found" =>
 	// Error Name: FileNotFound
 	// Error Message: a file with name %s was not found
-	re := regexp.MustCompile(`^([A-Z][a-zA-Z]+):\s(.*)`)
-	matches := re.FindStringSubmatch(errMsg)
+	matches := f.cfg.NameRegexp().FindStringSubmatch(errMsg)
 
 	if len(matches) < 3 {
 		return nil, grr.Errorf("NoErrorName: error name not found in error message")
 	}
 
-	errName := "Err" + matches[1]
+	errName := f.cfg.ErrorPrefix + matches[1]
 
 	if errName == "" {
 		return nil, grr.Errorf("NoErrorName: error name not found in error message")
 	}
 
-	errMsg = strings.TrimSpace(matches[2])
+	// the names of placeholders like {path:%s} are already the names of their fields
+	errMsg = grr.StripPlaceholders(strings.TrimSpace(matches[2]))
 
-	// we need to check if the error name, args, and message are already defined in the package
-	isDefined, isConflict := isAlreadyDefined(f, errName, args, errMsg)
+	// arguments wrapped with %w are the cause of the error, which is an error whatever their static type
+	args = slices.Clone(args)
+
+	for _, idx := range causeIndexes(errMsg) {
+		if idx < len(args) {
+			args[idx].Type = "error"
+		}
+	}
+
+	// an error already defined in the package is reused when its message and argument types are the same
+	existing, isDefined, isConflict := isAlreadyDefined(f, errName, args, errMsg)
 
 	if isConflict {
-		return nil, grr.Errorf("Conflict: error \"%s\" is already defined with different arguments or message", errName).
+		return nil, grr.Errorf("Conflict: error \"%s\" at %s is already defined at %s with different arguments or message", errName, params.Pos, f.definitionSite(existing)).
 			AddTrait(TrIsInternal, "false").
 			AddOp(op)
 	}
 
-	if isDefined {
-		return nil, grr.Errorf("AlreadyDefined: error \"%s\" is already defined with the same arguments and message", errName).
+	// the constructor of the existing error starts every error it builds with its defaults, which this call site would get too
+	hasDefaults := len(existing.DefaultTraits) > 0 || existing.DefaultOp != ""
+
+	if isDefined && hasDefaults && (!slices.Equal(existing.DefaultTraits, params.DefaultTraits) || existing.DefaultOp != params.DefaultOp) {
+		return nil, grr.Errorf("Conflict: error \"%s\" at %s is already defined at %s with default traits or op this call doesn't add", errName, params.Pos, f.definitionSite(existing)).
 			AddTrait(TrIsInternal, "false").
-			AddTrait(TrIsNonFatal, "true").
 			AddOp(op)
 	}
 
-	var buf bytes.Buffer
+	if isDefined {
+		return &existing, nil
+	}
 
-	err := errorStructTemplate.Execute(&buf, StructTemplateData{
+	pkgName, pkgPath := errorsPackage(f.pkg, f.cfg)
+
+	code, isSentinel, err := renderErrorCode(f.templates, StructTemplateData{
+		Name:    matches[1],
+		ID:      matches[1],
 		ErrName: errName,
 		Vars:    args,
 		Message: errMsg,
+		PkgName: pkgName,
+		PkgPath: pkgPath,
+		Pos:     params.Pos,
+		Traits:  params.Traits,
+
+		DefaultTraits: params.DefaultTraits,
+		DefaultOp:     params.DefaultOp,
+		ExportFields:  f.cfg.ExportFields,
 	})
 
 	if err != nil {
-		return nil, grr.Errorf("FailedToExecuteTemplate: something went wrong while generating: %v", strings.Builder{}).
+		return nil, grr.Errorf("FailedToExecuteTemplate: something went wrong while generating %s", errName).
 			AddError(err).
 			AddTrait(TrIsInternal, "true").
 			AddOp(op)
@@ -127,13 +207,56 @@ func (f *grrWalker) GenerateErrorStruct(params GenerateFileArgs) (*GeneratedErro
 
 	return &GeneratedError{
 		Name:          errName,
+		DefaultTraits: params.DefaultTraits,
+		DefaultOp:     params.DefaultOp,
 		Args:          args,
 		Msg:           errMsg,
-		GeneratedCode: buf.String(),
+		IsSentinel:    isSentinel,
+		GeneratedCode: code,
+		Checksum:      codeChecksum(code),
 	}, nil
 }
 
-func GenerateErrorFile(pkgName string, imports []string, errors map[string]GeneratedError) ([]byte, error) {
+// renderErrorCode executes the struct or sentinel template for an error and reports whether it was generated as a sentinel.
+// The output is checked to be valid Go declarations
+func renderErrorCode(tmpls *Templates, data StructTemplateData) (string, bool, error) {
+	// errors without arguments don't need a struct, an immutable sentinel is enough
+	isSentinel := len(data.Vars) == 0
+	tmpl := tmpls.Struct
+
+	if isSentinel {
+		tmpl = tmpls.Sentinel
+		data.Message = sentinelMessage(data.Message)
+	}
+
+	data.Version = TemplateDataVersion
+	data.Vars = slices.Clone(data.Vars)
+	data.Causes = []GrrGenErrorField{}
+
+	setAccessors(data.Vars, data.ExportFields)
+
+	for _, idx := range causeIndexes(data.Message) {
+		if idx < len(data.Vars) {
+			data.Vars[idx].IsCause = true
+			data.Causes = append(data.Causes, data.Vars[idx])
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", isSentinel, err
+	}
+
+	// the package clause is kept on the first line so that errors point at the lines of the template output
+	if _, err := formatGenerated(tmpl.Name(), append([]byte("package p;"), buf.Bytes()...)); err != nil {
+		return "", isSentinel, err
+	}
+
+	return buf.String(), isSentinel, nil
+}
+
+func GenerateErrorFile(tmpls *Templates, pkgName string, pkgPath string, imports []string, errors map[string]GeneratedError) ([]byte, error) {
 	op := "GenerateErrorFile"
 
 	pairs := utils.MapToPairs(errors)
@@ -142,10 +265,15 @@ func GenerateErrorFile(pkgName string, imports []string, errors map[string]Gener
 		return strings.Compare(i.Key, j.Key)
 	})
 
+	imports = slices.Clone(imports)
+	slices.Sort(imports)
+
 	var headerBuff bytes.Buffer
 
-	err := errorFileTemplate.Execute(&headerBuff, HeaderTemplateData{
+	err := tmpls.File.Execute(&headerBuff, HeaderTemplateData{
+		Version:         TemplateDataVersion,
 		PkgName:         pkgName,
+		PkgPath:         pkgPath,
 		Imports:         imports,
 		GeneratedErrors: utils.PairValues(pairs),
 	})
@@ -157,12 +285,16 @@ func GenerateErrorFile(pkgName string, imports []string, errors map[string]Gener
 			AddOp(op)
 	}
 
-	fmted, err := format.Source(headerBuff.Bytes())
+	code, err := pruneUnusedImports(headerBuff.Bytes())
 
 	if err != nil {
-		// write for debugging
-		os.WriteFile("grr.failed.gen.go", headerBuff.Bytes(), 0644)
+		// the syntax error is reported by formatGenerated below
+		code = headerBuff.Bytes()
+	}
 
+	fmted, err := formatGenerated(tmpls.File.Name(), code)
+
+	if err != nil {
 		return nil, grr.Errorf("FailedToFormat: failed to format source").
 			AddError(err).
 			AddOp(op)
@@ -171,38 +303,112 @@ func GenerateErrorFile(pkgName string, imports []string, errors map[string]Gener
 	return fmted, nil
 }
 
+// pruneUnusedImports drops imports that none of the generated errors use,
+// e.g. fmt when the file only contains sentinels
+func pruneUnusedImports(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+
+	if err != nil {
+		return src, err
+	}
+
+	// deleting an import removes it from file.Imports
+	for _, imp := range slices.Clone(file.Imports) {
+		path := strings.Trim(imp.Path.Value, "\"")
+
+		if !astutil.UsesImport(file, path) {
+			astutil.DeleteImport(fset, file, path)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := printer.Fprint(&buf, fset, file); err != nil {
+		return src, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// sentinelMessage returns what formatting a message without arguments prints, e.g. "100%% done" => "100% done"
+func sentinelMessage(format string) string {
+	return strings.ReplaceAll(format, "%%", "%")
+}
+
+// sentinelFormat returns the format printing the message of a sentinel, e.g. "100% done" => "100%% done"
+func sentinelFormat(msg string) string {
+	return strings.ReplaceAll(msg, "%", "%%")
+}
+
+// escapeString escapes s for a double quoted string literal, without the quotes
+func escapeString(s string) string {
+	quoted := strconv.Quote(s)
+
+	return quoted[1 : len(quoted)-1]
+}
+
 func GenDefaultImports() []string {
-	return []string{"fmt"}
+	return []string{"errors", "fmt", "log/slog", "time"}
 }
 
-func isAlreadyDefined(f *grrWalker, errName string, args []GrrGenErrorField, errMsg string) (bool, bool) {
+// isAlreadyDefined returns the error of the package called errName, if there is one, and whether it conflicts with
+// the given arguments and message. The names of the arguments don't matter since constructors take them by position
+func isAlreadyDefined(f *grrWalker, errName string, args []GrrGenErrorField, errMsg string) (GeneratedError, bool, bool) {
 	errs := utils.Merge(f.prevErrors, f.generatedErrors)
 
 	// check if the error name is already defined
-	if _, ok := errs[errName]; !ok {
-		return false, false
-	}
+	prevErr, ok := errs[errName]
 
-	prevErr := errs[errName]
+	if !ok {
+		return prevErr, false, false
+	}
 
 	// if it is defined, check if the arguments and message are the same
 	if len(prevErr.Args) != len(args) {
-		return true, true
+		return prevErr, true, true
 	}
 
 	if prevErr.Msg != errMsg {
-		return true, true
+		return prevErr, true, true
 	}
 
 	for i, arg := range args {
 		if prevErr.Args[i].Type != arg.Type {
-			return true, true
+			return prevErr, true, true
+		}
+	}
+
+	return prevErr, true, false
+}
+
+// definitionSite returns where an existing error comes from: the call site it was generated from in this run,
+// or else the first use of it outside of the generated files, falling back to its declaration
+func (f *grrWalker) definitionSite(genErr GeneratedError) token.Position {
+	if _, ok := f.prevErrors[genErr.Name]; !ok {
+		return genErr.Pos
+	}
+
+	_, errorsPath := errorsPackage(f.pkg, f.cfg)
+	site := genErr.Pos
+	found := false
+
+	for ident, obj := range f.info.Uses {
+		if obj.Pkg() == nil || obj.Pkg().Path() != errorsPath || (obj.Name() != genErr.Name && obj.Name() != "New"+genErr.Name) {
+			continue
+		}
+
+		pos := f.fset.Position(ident.Pos())
+
+		if f.cfg.IsGenerated(pos.Filename) {
+			continue
 		}
 
-		if prevErr.Args[i].Name != arg.Name {
-			return true, true
+		if !found || pos.Filename < site.Filename || (pos.Filename == site.Filename && pos.Offset < site.Offset) {
+			site = pos
+			found = true
 		}
 	}
 
-	return false, false
+	return site
 }
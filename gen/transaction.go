@@ -0,0 +1,221 @@
+package gen
+
+import (
+	"errors"
+	"go/format"
+	"os"
+	"path/filepath"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+// stagedChange is a fileChange waiting in a temp file next to its target
+type stagedChange struct {
+	fileChange
+	tmpPath string
+	// The content of the target before the run, to roll back to
+	original []byte
+	existed  bool
+	mode     os.FileMode
+	// The directories created for the target, outermost first
+	createdDirs []string
+}
+
+// commitChanges writes every change of a run or none of them.
+// Contents are validated with format.Source and staged to temp files next to their targets,
+// then renamed into place. If anything fails, the files already committed are restored
+func commitChanges(changes []fileChange) error {
+	for _, change := range changes {
+		if change.remove {
+			continue
+		}
+
+		if _, err := format.Source(change.content); err != nil {
+			return grr.Errorf("InvalidSource: %s would not be valid Go source", change.path).AddError(err)
+		}
+	}
+
+	staged := make([]*stagedChange, 0, len(changes))
+
+	// the temp files are removed before the directories created for them, which are only removed when they are empty
+	cleanup := func() {
+		for _, s := range staged {
+			if s.tmpPath != "" {
+				os.Remove(s.tmpPath)
+			}
+		}
+
+		for idx := len(staged) - 1; idx >= 0; idx-- {
+			removeDirs(staged[idx].createdDirs)
+		}
+	}
+
+	for _, change := range changes {
+		s, err := stageChange(change)
+
+		if err != nil {
+			cleanup()
+			return err
+		}
+
+		staged = append(staged, s)
+	}
+
+	for idx, s := range staged {
+		var err error
+
+		if s.remove {
+			err = os.Remove(s.path)
+		} else {
+			err = os.Rename(s.tmpPath, s.path)
+		}
+
+		if err != nil {
+			rollbackErr := rollback(staged[:idx])
+			cleanup()
+
+			if rollbackErr != nil {
+				return grr.Errorf("FailedToRollBack: failed to write %s, and some changes could not be rolled back", s.path).AddError(errors.Join(err, rollbackErr))
+			}
+
+			return grr.Errorf("FailedToCommit: failed to write %s, all changes were rolled back", s.path).AddError(err)
+		}
+
+		s.tmpPath = ""
+	}
+
+	return nil
+}
+
+// stageChange writes the content of a change to a temp file in the directory of its target
+func stageChange(change fileChange) (*stagedChange, error) {
+	s := &stagedChange{fileChange: change, mode: 0644}
+
+	info, err := os.Stat(change.path)
+
+	if err == nil {
+		s.existed = true
+		s.mode = info.Mode().Perm()
+
+		if s.original, err = os.ReadFile(change.path); err != nil {
+			return nil, grr.Errorf("FailedToRead: failed to read %s", change.path).AddError(err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, grr.Errorf("FailedToStat: failed to stat %s", change.path).AddError(err)
+	}
+
+	if change.remove {
+		return s, nil
+	}
+
+	// the errors package of the subpackage layout may not exist yet
+	if s.createdDirs, err = mkdirAll(filepath.Dir(change.path)); err != nil {
+		removeDirs(s.createdDirs)
+		return nil, grr.Errorf("FailedToCreateDir: failed to create %s", filepath.Dir(change.path)).AddError(err)
+	}
+
+	tmpPath, err := writeTemp(change.path, change.content, s.mode)
+
+	if err != nil {
+		removeDirs(s.createdDirs)
+		return nil, err
+	}
+
+	s.tmpPath = tmpPath
+
+	return s, nil
+}
+
+// mkdirAll creates dir along with its missing parents like os.MkdirAll, returning the directories it created, outermost first
+func mkdirAll(dir string) ([]string, error) {
+	missing := []string{}
+
+	for path := dir; ; path = filepath.Dir(path) {
+		if _, err := os.Stat(path); err == nil || !os.IsNotExist(err) || filepath.Dir(path) == path {
+			break
+		}
+
+		missing = append([]string{path}, missing...)
+	}
+
+	created := []string{}
+
+	for _, path := range missing {
+		err := os.Mkdir(path, 0755)
+
+		if os.IsExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return created, err
+		}
+
+		created = append(created, path)
+	}
+
+	return created, nil
+}
+
+// removeDirs removes the directories created by mkdirAll, innermost first, as long as they are empty
+func removeDirs(dirs []string) {
+	for idx := len(dirs) - 1; idx >= 0; idx-- {
+		os.Remove(dirs[idx])
+	}
+}
+
+// rollback restores the targets of committed changes to their original content.
+// It returns why the targets that could not be restored weren't, joined
+func rollback(committed []*stagedChange) error {
+	errs := []error{}
+
+	for idx := len(committed) - 1; idx >= 0; idx-- {
+		s := committed[idx]
+
+		if !s.existed {
+			if err := os.Remove(s.path); err != nil {
+				errs = append(errs, grr.Errorf("FailedToRollBack: failed to remove %s", s.path).AddError(err))
+			}
+
+			continue
+		}
+
+		tmpPath, err := writeTemp(s.path, s.original, s.mode)
+
+		if err == nil {
+			err = os.Rename(tmpPath, s.path)
+		}
+
+		if err != nil {
+			errs = append(errs, grr.Errorf("FailedToRollBack: failed to restore %s", s.path).AddError(err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeTemp writes content to a new temp file next to path, so that it can be renamed over path atomically
+func writeTemp(path string, content []byte, mode os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".grr-*")
+
+	if err != nil {
+		return "", grr.Errorf("FailedToCreateFile: failed to create temp file for %s", path).AddError(err)
+	}
+
+	_, err = tmp.Write(content)
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", grr.Errorf("FailedToWrite: failed to write temp file for %s", path).AddError(err)
+	}
+
+	return tmp.Name(), nil
+}
@@ -0,0 +1,92 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitChanges(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+
+	if err := os.WriteFile(existing, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []fileChange{
+		{path: existing, content: []byte("package main\n\nvar x = 1\n")},
+		{path: filepath.Join(dir, "internal", "errs", "grr.gen.go"), content: []byte("package errs\n")},
+	}
+
+	if err := commitChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range changes {
+		if content, err := os.ReadFile(change.path); err != nil || string(content) != string(change.content) {
+			t.Errorf("%s = %q, %v, want %q", change.path, content, err, change.content)
+		}
+	}
+
+	assertNoTempFiles(t, dir)
+}
+
+func TestCommitChangesRollback(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+
+	if err := os.WriteFile(existing, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []fileChange{
+		{path: existing, content: []byte("package main\n\nvar x = 1\n")},
+		{path: filepath.Join(dir, "internal", "errs", "grr.gen.go"), content: []byte("package errs\n")},
+		// removing a file that doesn't exist fails once the others are committed
+		{path: filepath.Join(dir, "missing.go"), remove: true},
+	}
+
+	if err := commitChanges(changes); err == nil {
+		t.Fatal("commitChanges() succeeded, want an error")
+	}
+
+	if content, err := os.ReadFile(existing); err != nil || string(content) != "package main\n" {
+		t.Errorf("main.go = %q, %v, want it rolled back", content, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "internal")); !os.IsNotExist(err) {
+		t.Errorf("the directories created for the run are left behind: %v", err)
+	}
+
+	assertNoTempFiles(t, dir)
+}
+
+func TestCommitChangesInvalidSource(t *testing.T) {
+	dir := t.TempDir()
+
+	changes := []fileChange{
+		{path: filepath.Join(dir, "internal", "errs", "grr.gen.go"), content: []byte("package errs\n\nfunc {\n")},
+	}
+
+	if err := commitChanges(changes); err == nil {
+		t.Fatal("commitChanges() succeeded, want an error")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "internal")); !os.IsNotExist(err) {
+		t.Errorf("a directory was created for invalid source: %v", err)
+	}
+}
+
+// assertNoTempFiles fails when a temp file or backup is left in dir
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && (filepath.Ext(path) == ".bak" || filepath.Base(path)[0] == '.') {
+			t.Errorf("left behind %s", path)
+		}
+
+		return nil
+	})
+}
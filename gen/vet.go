@@ -0,0 +1,138 @@
+package gen
+
+import (
+	"go/ast"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackHedaya/grr/grr"
+	"github.com/jackHedaya/grr/utils"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// vetFormats runs go vet's printf check over the files of the package and returns what it reports.
+// grr.Errorf is checked like fmt.Errorf, which it wraps, so %w is accepted
+func (walker *grrWalker) vetFormats() ([]analysis.Diagnostic, error) {
+	diagnostics := []analysis.Diagnostic{}
+
+	pass := &analysis.Pass{
+		Analyzer:   printf.Analyzer,
+		Fset:       walker.fset,
+		Files:      walker.pkg.Syntax,
+		Pkg:        walker.pkg.Types,
+		TypesInfo:  walker.vetInfo(),
+		TypesSizes: walker.pkg.TypesSizes,
+		ResultOf: map[*analysis.Analyzer]any{
+			inspect.Analyzer: inspector.New(walker.pkg.Syntax),
+		},
+		Report: func(diagnostic analysis.Diagnostic) {
+			diagnostics = append(diagnostics, diagnostic)
+		},
+		// facts of other functions come from analyzing their packages, which the generator doesn't, so their calls aren't checked
+		ImportObjectFact: func(types.Object, analysis.Fact) bool {
+			return false
+		},
+		ExportObjectFact: func(types.Object, analysis.Fact) {},
+		ImportPackageFact: func(*types.Package, analysis.Fact) bool {
+			return false
+		},
+		ExportPackageFact: func(analysis.Fact) {},
+		AllObjectFacts: func() []analysis.ObjectFact {
+			return nil
+		},
+		AllPackageFacts: func() []analysis.PackageFact {
+			return nil
+		},
+	}
+
+	if _, err := printf.Analyzer.Run(pass); err != nil {
+		return nil, grr.Errorf("FailedToVet: go vet's printf check failed on package %s", walker.pkg.PkgPath).AddError(err)
+	}
+
+	return diagnostics, nil
+}
+
+// vetInfo returns the type info of the package with every grr.Errorf resolved to a stand-in fmt.Errorf of the same signature,
+// which the printf check knows by name
+func (walker *grrWalker) vetInfo() *types.Info {
+	info := *walker.info
+	info.Uses = maps.Clone(walker.info.Uses)
+
+	fmtPkg := types.NewPackage("fmt", "fmt")
+	standIns := map[*types.Func]*types.Func{}
+
+	for ident, obj := range info.Uses {
+		fn, ok := obj.(*types.Func)
+
+		if !ok || fn.Name() != walker.cfg.Errorf || fn.Pkg() == nil || !utils.Contains(walker.cfg.ImportPaths, fn.Pkg().Path()) {
+			continue
+		}
+
+		if standIns[fn] == nil {
+			standIns[fn] = types.NewFunc(fn.Pos(), fmtPkg, "Errorf", fn.Type().(*types.Signature))
+		}
+
+		info.Uses[ident] = standIns[fn]
+	}
+
+	return &info
+}
+
+// checkFormat returns the first problem go vet's printf check reports for a grr.Errorf call, as an InvalidFormat error.
+// Problems of grr.Errorf and fmt.Errorf calls nested in the arguments belong to those calls
+func (walker *grrWalker) checkFormat(callExpr *ast.CallExpr) error {
+	if walker.vetDiagnostics == nil {
+		diagnostics, err := walker.vetFormats()
+
+		if err != nil {
+			return err
+		}
+
+		walker.vetDiagnostics = diagnostics
+	}
+
+	fn, ok := typeutil.Callee(walker.info, callExpr).(*types.Func)
+
+	if !ok {
+		return nil
+	}
+
+	nested := []ast.Node{}
+
+	for _, arg := range callExpr.Args {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+
+			if !ok {
+				return true
+			}
+
+			if callee, ok := typeutil.Callee(walker.info, call).(*types.Func); ok && (callee == fn || callee.FullName() == "fmt.Errorf") {
+				nested = append(nested, call)
+				return false
+			}
+
+			return true
+		})
+	}
+
+	for _, diagnostic := range walker.vetDiagnostics {
+		if diagnostic.Pos < callExpr.Pos() || diagnostic.Pos >= callExpr.End() || !strings.HasPrefix(diagnostic.Message, "fmt.Errorf ") {
+			continue
+		}
+
+		if slices.ContainsFunc(nested, func(n ast.Node) bool { return diagnostic.Pos >= n.Pos() && diagnostic.Pos < n.End() }) {
+			continue
+		}
+
+		return grr.Errorf("InvalidFormat: %s", "grr.Errorf"+strings.TrimPrefix(diagnostic.Message, "fmt.Errorf"))
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+)
+
+const vetMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+func main() {}
+
+func A(p string, n int) []error {
+	return []error{
+		grr.Errorf("UnknownVerb: %z happened", p),
+		grr.Errorf("WrongType: %d items", p),
+		grr.Errorf("MissingArg: %s and %s", p),
+		grr.Errorf("ExtraArg: %s", p, n),
+		grr.Errorf("NotAnError: %w", p),
+		grr.Errorf("ReadFailed: reading %s: %w", p, os.ErrNotExist),
+		grr.Errorf("Outer: %w", fmt.Errorf("inner %z", n)),
+	}
+}
+`
+
+// Formats go vet rejects are reported as InvalidFormat, while %w and the problems of nested calls are not
+func TestInvalidFormat(t *testing.T) {
+	dir := writeModule(t, map[string]string{"main.go": vetMain})
+
+	want := map[int]string{
+		14: "grr.Errorf format %z has unknown verb z",
+		15: "grr.Errorf format %d has arg p of wrong type string",
+		16: "grr.Errorf format %s reads arg #2, but call has 1 arg",
+		17: "grr.Errorf call needs 1 arg but has 2 args",
+		18: "grr.Errorf format %w has arg p of wrong type string",
+	}
+
+	for _, diagnostic := range generate(t, dir) {
+		if diagnostic.Code != "InvalidFormat" {
+			continue
+		}
+
+		if !strings.Contains(diagnostic.Message, want[diagnostic.Line]) || want[diagnostic.Line] == "" {
+			t.Errorf("line %d: got %q, want %q", diagnostic.Line, diagnostic.Message, want[diagnostic.Line])
+		}
+
+		delete(want, diagnostic.Line)
+	}
+
+	for line, msg := range want {
+		t.Errorf("line %d: no InvalidFormat, want %q", line, msg)
+	}
+
+	main := readFile(t, dir, "main.go")
+
+	for _, converted := range []string{"NewErrReadFailed(p, os.ErrNotExist)", "NewErrOuter(fmt.Errorf("} {
+		if !strings.Contains(main, converted) {
+			t.Errorf("main.go doesn't contain %s:\n%s", converted, main)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package grr
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type Error interface {
@@ -25,14 +28,31 @@ type Error interface {
 var _ Error = &grrError{}
 
 type grrError struct {
-	err    error
-	msg    string
-	op     string
-	traits map[Trait]any
+	err     error
+	msg     string
+	op      string
+	traits  map[Trait]any
+	created time.Time
+	// The sentinel this error was annotated from, if any
+	sentinel *sentinel
 }
 
+// Errorf formats a new error. Named placeholders like {path:%s} are formatted with their verb,
+// so unlike with fmt.Errorf, "{path:%s}" prints the argument without the braces and the name.
+// Like fmt.Errorf, the error wraps the arguments formatted with %w, joined when there are several
 func Errorf(format string, args ...interface{}) Error {
-	return &grrError{msg: fmt.Sprintf(format, args...), traits: map[Trait]any{}}
+	formatted := fmt.Errorf(StripPlaceholders(format), args...)
+	e := &grrError{msg: formatted.Error(), traits: map[Trait]any{}, created: Now()}
+
+	switch wrapped := formatted.(type) {
+	case interface{ Unwrap() error }:
+		e.err = wrapped.Unwrap()
+
+	case interface{ Unwrap() []error }:
+		e.err = errors.Join(wrapped.Unwrap()...)
+	}
+
+	return e
 }
 
 func (e *grrError) Error() string {
@@ -43,6 +63,11 @@ func (e *grrError) Unwrap() error {
 	return e.err
 }
 
+// Is reports whether e was annotated from the target sentinel
+func (e *grrError) Is(target error) bool {
+	return e.sentinel != nil && e.sentinel == target
+}
+
 func (e *grrError) UnwrapAll() error {
 	return UnwrapAll(e)
 }
@@ -83,6 +108,18 @@ func (e *grrError) GetTraits() map[Trait]any {
 	return traits
 }
 
+func (e *grrError) CreatedAt() time.Time {
+	return e.created
+}
+
+func (e *grrError) LogValue() slog.Value {
+	return LogValue(e)
+}
+
+func (e *grrError) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(e)
+}
+
 func (e *grrError) Trace() {
 	Trace(e)
 }
@@ -95,44 +132,82 @@ func Trace(err error) {
 	fmt.Println(Strace(err))
 }
 
+// Strace formats the chain of err with the root cause first, and each error that wraps the errors above it prefixed with |-.
+// The causes of an error that wraps several, e.g. with errors.Join, are indented as branches of their own
 func Strace(err error) string {
-	if !IsGrr(err) {
-		return err.Error()
-	}
+	var root *traceLink
 
-	var errs []error
+	// the links by depth on the way to the current one
+	var path []*traceLink
 
-	for {
-		errs = append(errs, err)
+	Walk(err, func(depth int, e error) bool {
+		link := &traceLink{err: e}
 
-		if casted, ok := err.(Error); !ok || casted.Unwrap() == nil {
-			break
+		if depth == 0 {
+			root = link
+		} else {
+			path[depth-1].causes = append(path[depth-1].causes, link)
 		}
 
-		err = err.(Error).Unwrap()
-	}
+		path = append(path[:depth], link)
+		return true
+	})
 
 	var trace strings.Builder
 
-	for i := len(errs) - 1; i >= 0; i-- {
-		if i != len(errs)-1 {
-			trace.WriteString("|- ")
+	if root != nil {
+		writeTrace(&trace, root, "")
+	}
+
+	if recordingOccurrences() {
+		process := Process()
+		trace.WriteString(process.String() + "\n")
+	}
+
+	return trace.String()
+}
+
+// traceLink is an error of a chain along with the errors it wraps
+type traceLink struct {
+	err    error
+	causes []*traceLink
+}
+
+// writeTrace writes the causes of link before link itself
+func writeTrace(trace *strings.Builder, link *traceLink, indent string) {
+	switch len(link.causes) {
+	case 0:
+
+	case 1:
+		writeTrace(trace, link.causes[0], indent)
+
+	default:
+		for _, cause := range link.causes {
+			writeTrace(trace, cause, indent+"  ")
 		}
+	}
 
-		trace.WriteString(errs[i].Error())
+	trace.WriteString(indent)
 
-		if IsGrr(errs[i]) {
-			op := errs[i].(Error).GetOp()
+	if len(link.causes) > 0 {
+		trace.WriteString("|- ")
+	}
 
-			if op != "" {
-				trace.WriteString(fmt.Sprintf("; op: %s", op))
-			}
+	trace.WriteString(link.err.Error())
+
+	if IsGrr(link.err) {
+		op := link.err.(Error).GetOp()
+
+		if op != "" {
+			trace.WriteString(fmt.Sprintf("; op: %s", op))
 		}
+	}
 
-		trace.WriteString("\n")
+	if created, ok := link.err.(interface{ CreatedAt() time.Time }); ok && recordingOccurrences() && !created.CreatedAt().IsZero() {
+		trace.WriteString(fmt.Sprintf("; at: %s", created.CreatedAt().Format(time.RFC3339Nano)))
 	}
 
-	return trace.String()
+	trace.WriteString("\n")
 }
 
 func IsGrr(err error) bool {
@@ -140,64 +215,54 @@ func IsGrr(err error) bool {
 	return ok
 }
 
+// Finds the first error in e's chain (including e) that can be converted to the type of err
 func AsGrr(e Error, err error) (Error, bool) {
 	E := reflect.TypeOf(err)
 
-	var last error = e.UnwrapAll()
+	if E == nil {
+		return nil, false
+	}
+
+	var found Error
 
-	for {
-		if last == nil {
-			return nil, false
+	Walk(e, func(_ int, link error) bool {
+		if !IsGrr(link) || !reflect.TypeOf(link).ConvertibleTo(E) {
+			return true
 		}
 
-		if _, ok := last.(Error); !ok {
-			return nil, false
-		}
+		converted, ok := reflect.ValueOf(link).Convert(E).Interface().(Error)
 
-		if reflect.TypeOf(last).ConvertibleTo(E) {
-			return reflect.ValueOf(last).Convert(E).Interface().(Error), true
+		if !ok {
+			return true
 		}
 
-		last = last.(Error).Unwrap()
-	}
+		found = converted
+		return false
+	})
+
+	return found, found != nil
 }
 
 // Gets the trait value of the **innermost** grr.Error in the chain
 // This let's you assign a trait to the root error and have it propogate down the stack
 func GetTrait(err error, key Trait) (any, bool) {
-	if !IsGrr(err) {
+	bottomGrr, ok := innermost(err, IsGrr).(Error)
+
+	if !ok {
 		return nil, false
 	}
 
-	bottomGrr := UnwrapAllGrr(err.(Error))
-
 	return bottomGrr.GetTrait(key)
 }
 
 // Unwraps to the bottom-most grr.Error in the chain. This is the closest grr.Error to the root error
 func UnwrapAllGrr(err Error) Error {
-	for {
-		if casted, ok := err.Unwrap().(Error); ok {
-			err = casted
-			continue
-		}
-
-		return err
-	}
+	return innermost(err, IsGrr).(Error)
 }
 
+// Unwraps to the innermost error in the chain that isn't a grr.Error, or nil if there is none
 func UnwrapAll(e Error) error {
-	last := e.Unwrap()
-
-	for {
-		if last == nil {
-			return nil
-		}
-
-		if _, ok := last.(Error); !ok {
-			return last
-		}
-
-		last = last.(Error).Unwrap()
-	}
+	return innermost(e, func(err error) bool {
+		return !IsGrr(err)
+	})
 }
@@ -0,0 +1,101 @@
+package grr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStrace(t *testing.T) {
+	cyclic := Errorf("Cyclic: points at itself")
+	cyclic.AddError(cyclic)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "single",
+			err:  Errorf("NotFound: user was not found"),
+			want: "NotFound: user was not found\n",
+		},
+		{
+			name: "chain",
+			err:  Errorf("Outer: failed").AddOp("pkg.Outer").AddError(fmt.Errorf("wrapped: %w", errors.New("root"))),
+			want: "root\n|- wrapped: root\n|- Outer: failed; op: pkg.Outer\n",
+		},
+		{
+			name: "join",
+			err:  Errorf("Outer: failed").AddError(errors.Join(Errorf("A: a").AddError(errors.New("a0")), errors.New("b"))),
+			want: "  a0\n  |- A: a\n  b\n|- A: a\nb\n|- Outer: failed\n",
+		},
+		{
+			name: "cycle",
+			err:  cyclic,
+			want: "Cyclic: points at itself\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Strace(test.err); got != test.want {
+				t.Errorf("Strace() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestErrorfWraps(t *testing.T) {
+	root := errors.New("root")
+
+	tests := []struct {
+		name string
+		err  Error
+		msg  string
+	}{
+		{name: "w", err: Errorf("ReadFailed: reading %s: %w", "a", root), msg: "ReadFailed: reading a: root"},
+		{name: "placeholder", err: Errorf("ReadFailed: reading {path:%s}: {cause:%w}", "a", root), msg: "ReadFailed: reading a: root"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.err.Error(); got != test.msg {
+				t.Errorf("Error() = %q, want %q", got, test.msg)
+			}
+
+			if test.err.Unwrap() != root {
+				t.Errorf("Unwrap() = %v, want %v", test.err.Unwrap(), root)
+			}
+		})
+	}
+
+	if err := Errorf("NotFound: %v was not found, 100%% sure", root); err.Error() != "NotFound: root was not found, 100% sure" || err.Unwrap() != nil {
+		t.Errorf("got %q wrapping %v, want nothing wrapped without %%w", err.Error(), err.Unwrap())
+	}
+}
+
+func TestErrorfWrapsSeveral(t *testing.T) {
+	root := errors.New("root")
+	other := errors.New("other")
+
+	err := Errorf("ReadFailed: %w and %w", root, other)
+
+	if err.Error() != "ReadFailed: root and other" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "ReadFailed: root and other")
+	}
+
+	joined, ok := err.Unwrap().(interface{ Unwrap() []error })
+
+	if !ok {
+		t.Fatalf("Unwrap() = %#v, want the %%w arguments joined", err.Unwrap())
+	}
+
+	if wrapped := joined.Unwrap(); len(wrapped) != 2 || wrapped[0] != root || wrapped[1] != other {
+		t.Errorf("the joined error wraps %v, want [root other]", wrapped)
+	}
+
+	if !errors.Is(err, root) || !errors.Is(err, other) {
+		t.Errorf("errors.Is doesn't find both %%w arguments")
+	}
+}
@@ -0,0 +1,50 @@
+// Package grrmetrics publishes the error counters kept by grr.Observe, through expvar or in the Prometheus text format.
+// It is a separate package so that programs importing grr don't pull in net/http or register /debug/vars
+package grrmetrics
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+// MetricName is the name the counters are published under
+const MetricName = "grr_errors_total"
+
+// PublishExpvar publishes the counters under /debug/vars. Like expvar.Publish, it panics when called twice
+func PublishExpvar() {
+	expvar.Publish(MetricName, expvar.Func(func() any {
+		return grr.ObservedErrors()
+	}))
+}
+
+// Handler serves the counters in the Prometheus text exposition format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out strings.Builder
+
+		out.WriteString("# HELP " + MetricName + " Number of errors observed by grr.Observe.\n")
+		out.WriteString("# TYPE " + MetricName + " counter\n")
+
+		for _, count := range grr.ObservedErrors() {
+			out.WriteString(fmt.Sprintf("%s{%s} %d\n", MetricName, promLabels(count.ErrorLabels), count.Count))
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write([]byte(out.String()))
+	})
+}
+
+func promLabels(l grr.ErrorLabels) string {
+	return fmt.Sprintf(`type="%s",id="%s",op="%s",code="%s"`,
+		promEscape(l.Type), promEscape(l.ID), promEscape(l.Op), promEscape(l.Code))
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(value string) string {
+	return promEscaper.Replace(value)
+}
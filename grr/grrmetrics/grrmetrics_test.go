@@ -0,0 +1,52 @@
+package grrmetrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackHedaya/grr/grr"
+)
+
+func TestHandler(t *testing.T) {
+	grr.Observe(grr.Errorf("NotFound: user \"a\" was not found").AddOp("pkg.Get\nv2"))
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	header := "# HELP grr_errors_total Number of errors observed by grr.Observe.\n# TYPE grr_errors_total counter\n"
+	counter := `grr_errors_total{type="*grr.grrError",id="NotFound",op="pkg.Get\nv2",code=""} 1` + "\n"
+
+	// other tests of the package observe errors too
+	if got := recorder.Body.String(); !strings.HasPrefix(got, header) || !strings.Contains(got, counter) {
+		t.Errorf("body = %q, want the header and %q", got, counter)
+	}
+
+	if got := recorder.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	grr.Observe(grr.Errorf("Timeout: request timed out"))
+
+	PublishExpvar()
+
+	published := expvar.Get(MetricName)
+
+	if published == nil {
+		t.Fatalf("%s isn't published", MetricName)
+	}
+
+	want, err := json.Marshal(grr.ObservedErrors())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := published.String(); got != string(want) {
+		t.Errorf("%s = %s, want %s", MetricName, got, want)
+	}
+}
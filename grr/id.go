@@ -0,0 +1,88 @@
+package grr
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var idPattern = regexp.MustCompile(`^([A-Z][a-zA-Z]+):\s`)
+
+// ID returns the identifier of the outermost grr.Error in the chain, or "" if there is none.
+// For grr.Errorf errors this is the name prefix of the message ("FileNotFound: ..." => FileNotFound),
+// and for generated errors it is the type name without its Err prefix (ErrFileNotFound => FileNotFound)
+func ID(err error) string {
+	var id string
+
+	Walk(err, func(_ int, e error) bool {
+		if !IsGrr(e) {
+			return true
+		}
+
+		id = idOf(e)
+		return false
+	})
+
+	return id
+}
+
+// Op returns the outermost op set in the chain, or "" if there is none
+func Op(err error) string {
+	var op string
+
+	Walk(err, func(_ int, e error) bool {
+		if grrErr, ok := e.(Error); ok {
+			op = grrErr.GetOp()
+		}
+
+		return op == ""
+	})
+
+	return op
+}
+
+// Code returns the canonical code of the chain as set with AddTrait(TrCode, ...), or "" if there is none.
+// Like GetTrait, the innermost grr.Error decides
+func Code(err error) string {
+	code, ok := GetTrait(err, TrCode)
+
+	if !ok || code == nil {
+		return ""
+	}
+
+	return fmt.Sprint(code)
+}
+
+func idOf(err error) string {
+	var msg string
+
+	switch e := err.(type) {
+	case *grrError:
+		if e.sentinel != nil && e.sentinel.id != "" {
+			return e.sentinel.id
+		}
+
+		msg = e.msg
+	case *sentinel:
+		if e.id != "" {
+			return e.id
+		}
+
+		msg = e.msg
+	default:
+		t := reflect.TypeOf(err)
+
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		return strings.TrimPrefix(t.Name(), "Err")
+	}
+
+	if matches := idPattern.FindStringSubmatch(msg); len(matches) == 2 {
+		return matches[1]
+	}
+
+	return ""
+}
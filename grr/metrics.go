@@ -0,0 +1,73 @@
+package grr
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ErrorLabels identifies a counter kept by Observe
+type ErrorLabels struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Op   string `json:"op"`
+	Code string `json:"code"`
+}
+
+// ErrorCount is a single counter kept by Observe. See the grrmetrics package to publish them
+type ErrorCount struct {
+	ErrorLabels
+	Count uint64 `json:"count"`
+}
+
+var observed = struct {
+	sync.Mutex
+	counts map[ErrorLabels]uint64
+}{counts: map[ErrorLabels]uint64{}}
+
+// Observe counts err by the type and ID of its outermost grr.Error, its op and its canonical code.
+// Errors without a grr.Error in their chain are counted by their own type. Nil errors are ignored
+func Observe(err error) {
+	if err == nil {
+		return
+	}
+
+	typed := err
+
+	Walk(err, func(_ int, e error) bool {
+		if IsGrr(e) {
+			typed = e
+			return false
+		}
+
+		return true
+	})
+
+	labels := ErrorLabels{
+		Type: fmt.Sprintf("%T", typed),
+		ID:   ID(err),
+		Op:   Op(err),
+		Code: Code(err),
+	}
+
+	observed.Lock()
+	observed.counts[labels]++
+	observed.Unlock()
+}
+
+// ObservedErrors returns a snapshot of the counters kept by Observe, sorted by labels
+func ObservedErrors() []ErrorCount {
+	observed.Lock()
+	counts := make([]ErrorCount, 0, len(observed.counts))
+	for labels, count := range observed.counts {
+		counts = append(counts, ErrorCount{ErrorLabels: labels, Count: count})
+	}
+	observed.Unlock()
+
+	slices.SortFunc(counts, func(a, b ErrorCount) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.ID, b.ID), cmp.Compare(a.Op, b.Op), cmp.Compare(a.Code, b.Code))
+	})
+
+	return counts
+}
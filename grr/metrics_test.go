@@ -0,0 +1,36 @@
+package grr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// resetObserved forgets the counters kept by Observe
+func resetObserved() {
+	observed.Lock()
+	observed.counts = map[ErrorLabels]uint64{}
+	observed.Unlock()
+}
+
+func TestObserve(t *testing.T) {
+	resetObserved()
+	defer resetObserved()
+
+	notFound := Errorf("NotFound: user was not found").AddOp("pkg.Get").AddTrait(TrCode, "NotFound")
+
+	Observe(notFound)
+	Observe(fmt.Errorf("wrapped: %w", notFound))
+	Observe(errors.New("plain"))
+	Observe(nil)
+
+	want := []ErrorCount{
+		{ErrorLabels: ErrorLabels{Type: "*errors.errorString"}, Count: 1},
+		{ErrorLabels: ErrorLabels{Type: "*grr.grrError", ID: "NotFound", Op: "pkg.Get", Code: "NotFound"}, Count: 2},
+	}
+
+	if got := ObservedErrors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ObservedErrors() = %v, want %v", got, want)
+	}
+}
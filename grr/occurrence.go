@@ -0,0 +1,208 @@
+package grr
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Keys used for occurrence metadata in span attributes and slog output.
+// host.name and service.version are OpenTelemetry semantic convention keys
+const (
+	AttrCreatedAt = "grr.created_at"
+	AttrHostName  = "host.name"
+	AttrVersion   = "service.version"
+	AttrModule    = "grr.module"
+	AttrRevision  = "grr.revision"
+)
+
+var clock atomic.Pointer[func() time.Time]
+
+// RecordOccurrences makes new grr errors record when they were created using clock (usually time.Now),
+// and makes Strace, SpanAttributes and LogValue include the process metadata from Process.
+// Passing nil turns it off again, which is the default
+func RecordOccurrences(now func() time.Time) {
+	if now == nil {
+		clock.Store(nil)
+		return
+	}
+
+	clock.Store(&now)
+}
+
+func recordingOccurrences() bool {
+	return clock.Load() != nil
+}
+
+// Now returns the creation time to record on a new grr error, or the zero time when occurrences aren't recorded
+func Now() time.Time {
+	now := clock.Load()
+
+	if now == nil {
+		return time.Time{}
+	}
+
+	return (*now)()
+}
+
+// CreatedAt returns when the innermost grr.Error in the chain that recorded a time was created
+func CreatedAt(err error) (time.Time, bool) {
+	created, ok := innermost(err, func(e error) bool {
+		c, ok := e.(interface{ CreatedAt() time.Time })
+		return ok && !c.CreatedAt().IsZero()
+	}).(interface{ CreatedAt() time.Time })
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return created.CreatedAt(), true
+}
+
+// ProcessInfo describes the process and build an error occurred in
+type ProcessInfo struct {
+	Hostname string `json:"hostname,omitempty"`
+	Module   string `json:"module,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// Process returns the hostname and the build information of the running binary
+func Process() ProcessInfo {
+	return processInfo()
+}
+
+// processInfo reads the process metadata once. Tests replace it with fixed metadata
+var processInfo = sync.OnceValue(func() ProcessInfo {
+	var info ProcessInfo
+
+	info.Hostname, _ = os.Hostname()
+
+	build, ok := debug.ReadBuildInfo()
+
+	if !ok {
+		return info
+	}
+
+	info.Module = build.Main.Path
+	info.Version = build.Main.Version
+
+	for _, setting := range build.Settings {
+		if setting.Key == "vcs.revision" {
+			info.Revision = setting.Value
+		}
+	}
+
+	return info
+})
+
+// occurrenceAttrs returns the occurrence metadata of err, or nil when occurrences aren't recorded
+func occurrenceAttrs(err error) []Attr {
+	if !recordingOccurrences() {
+		return nil
+	}
+
+	var attrs []Attr
+
+	if created, ok := CreatedAt(err); ok {
+		attrs = append(attrs, Attr{Key: AttrCreatedAt, Value: created.Format(time.RFC3339Nano)})
+	}
+
+	process := Process()
+
+	if process.Hostname != "" {
+		attrs = append(attrs, Attr{Key: AttrHostName, Value: process.Hostname})
+	}
+
+	if process.Version != "" {
+		attrs = append(attrs, Attr{Key: AttrVersion, Value: process.Version})
+	}
+
+	if process.Module != "" {
+		attrs = append(attrs, Attr{Key: AttrModule, Value: process.Module})
+	}
+
+	if process.Revision != "" {
+		attrs = append(attrs, Attr{Key: AttrRevision, Value: process.Revision})
+	}
+
+	return attrs
+}
+
+// String formats the process info for Strace, e.g. host: web-1; version: (devel); module: example.com/app; revision: 1a2b3c
+func (p ProcessInfo) String() string {
+	info := fmt.Sprintf("host: %s; version: %s", p.Hostname, p.Version)
+
+	if p.Module != "" {
+		info += "; module: " + p.Module
+	}
+
+	if p.Revision != "" {
+		info += "; revision: " + p.Revision
+	}
+
+	return info
+}
+
+// jsonError is the JSON form of an error chain written by MarshalJSON
+type jsonError struct {
+	Message   string        `json:"message"`
+	Type      string        `json:"type"`
+	ID        string        `json:"id,omitempty"`
+	Op        string        `json:"op,omitempty"`
+	Traits    map[Trait]any `json:"traits,omitempty"`
+	CreatedAt *time.Time    `json:"createdAt,omitempty"`
+	Process   *ProcessInfo  `json:"process,omitempty"`
+}
+
+// MarshalJSON describes err as JSON: its message, type, ID, op and traits, like SpanAttributes,
+// along with when it was created and the process and build it occurred in when RecordOccurrences is on
+func MarshalJSON(err error) ([]byte, error) {
+	if err == nil {
+		return []byte("null"), nil
+	}
+
+	out := jsonError{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+		ID:      ID(err),
+		Op:      Op(err),
+		Traits:  chainTraits(err),
+	}
+
+	if recordingOccurrences() {
+		if created, ok := CreatedAt(err); ok {
+			out.CreatedAt = &created
+		}
+
+		process := Process()
+		out.Process = &process
+	}
+
+	return json.Marshal(out)
+}
+
+// LogValue describes err for slog: its message, ID, op, traits and occurrence metadata
+func LogValue(err error) slog.Value {
+	if err == nil {
+		return slog.Value{}
+	}
+
+	attrs := []slog.Attr{slog.String("msg", err.Error())}
+
+	for _, attr := range SpanAttributes(err) {
+		switch attr.Key {
+		case AttrExceptionType, AttrExceptionMessage, AttrExceptionStacktrace:
+			continue
+		}
+
+		attrs = append(attrs, slog.Any(attr.Key, attr.Value))
+	}
+
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,110 @@
+package grr
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordFixedOccurrences records occurrences at a fixed time in a known process until the test ends
+func recordFixedOccurrences(t *testing.T, at time.Time) {
+	process := processInfo
+
+	processInfo = func() ProcessInfo {
+		return ProcessInfo{Hostname: "web-1", Module: "example.com/app", Version: "(devel)", Revision: "1a2b3c"}
+	}
+
+	RecordOccurrences(func() time.Time { return at })
+
+	t.Cleanup(func() {
+		processInfo = process
+		RecordOccurrences(nil)
+	})
+}
+
+func TestMarshalJSON(t *testing.T) {
+	at := time.Date(2024, 4, 26, 12, 0, 0, 0, time.UTC)
+	recordFixedOccurrences(t, at)
+
+	err := Errorf("NotFound: user was not found").AddOp("pkg.Get").AddTrait(TrCode, "NotFound")
+
+	data, marshalErr := json.Marshal(err)
+
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+
+	var got map[string]any
+
+	if marshalErr := json.Unmarshal(data, &got); marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+
+	want := map[string]any{
+		"message":   "NotFound: user was not found",
+		"type":      "*grr.grrError",
+		"id":        "NotFound",
+		"op":        "pkg.Get",
+		"traits":    map[string]any{"Code": "NotFound"},
+		"createdAt": "2024-04-26T12:00:00Z",
+		"process": map[string]any{
+			"hostname": "web-1",
+			"module":   "example.com/app",
+			"version":  "(devel)",
+			"revision": "1a2b3c",
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json = %s", data)
+	}
+}
+
+func TestMarshalJSONWithoutOccurrences(t *testing.T) {
+	data, err := MarshalJSON(Sentinel("NotFound: user was not found"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"message":"NotFound: user was not found","type":"*grr.sentinel","id":"NotFound"}`; string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+}
+
+func TestOccurrenceMetadata(t *testing.T) {
+	at := time.Date(2024, 4, 26, 12, 0, 0, 0, time.UTC)
+	recordFixedOccurrences(t, at)
+
+	err := Errorf("NotFound: user was not found")
+
+	trace := Strace(err)
+
+	if want := "NotFound: user was not found; at: 2024-04-26T12:00:00Z\nhost: web-1; version: (devel); module: example.com/app; revision: 1a2b3c\n"; trace != want {
+		t.Errorf("Strace() = %q, want %q", trace, want)
+	}
+
+	attrs := map[string]any{}
+
+	for _, attr := range SpanAttributes(err) {
+		attrs[attr.Key] = attr.Value
+	}
+
+	for key, value := range map[string]any{
+		AttrCreatedAt: "2024-04-26T12:00:00Z",
+		AttrHostName:  "web-1",
+		AttrVersion:   "(devel)",
+		AttrModule:    "example.com/app",
+		AttrRevision:  "1a2b3c",
+	} {
+		if attrs[key] != value {
+			t.Errorf("%s = %v, want %v", key, attrs[key], value)
+		}
+	}
+
+	if logged := LogValue(err).String(); !strings.Contains(logged, "1a2b3c") {
+		t.Errorf("LogValue() = %s, want the revision", logged)
+	}
+}
@@ -0,0 +1,21 @@
+package grr
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PlaceholderPattern matches the named placeholders of a message, e.g. {path:%s}.
+// The first group is the name and the second the verb formatting the argument
+var PlaceholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*):(%[^{}]*)\}`)
+
+// StripPlaceholders replaces the named placeholders of a format string with their verbs,
+// e.g. "file {path:%s} not found" => "file %s not found"
+func StripPlaceholders(format string) string {
+	// most formats have no placeholders, which is cheaper to tell than to match
+	if !strings.Contains(format, "{") {
+		return format
+	}
+
+	return PlaceholderPattern.ReplaceAllString(format, "$2")
+}
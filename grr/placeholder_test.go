@@ -0,0 +1,21 @@
+package grr
+
+import "testing"
+
+func TestStripPlaceholders(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "file %s not found", want: "file %s not found"},
+		{format: "file {path:%s} not found", want: "file %s not found"},
+		{format: "{count:%5.2f} of {total:%[1]d}", want: "%5.2f of %[1]d"},
+		{format: "literal {braces} and {1:%s}", want: "literal {braces} and {1:%s}"},
+	}
+
+	for _, test := range tests {
+		if got := StripPlaceholders(test.format); got != test.want {
+			t.Errorf("StripPlaceholders(%q) = %q, want %q", test.format, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package grr
+
+import (
+	"log/slog"
+	"time"
+)
+
+// sentinel implements the Error interface
+var _ Error = &sentinel{}
+
+// sentinel is an immutable grr.Error meant to be stored in a package level variable.
+// Annotating it never changes the shared value; AddTrait, AddOp and AddError return a fresh grr.Error
+// that still matches the sentinel with errors.Is
+type sentinel struct {
+	// The ID of a generated sentinel, whose message doesn't start with it
+	id  string
+	msg string
+}
+
+// Sentinel returns an immutable grr.Error that is safe to share, e.g.
+//
+//	var ErrNotFound = grr.Sentinel("NotFound: resource not found")
+func Sentinel(msg string) Error {
+	return &sentinel{msg: msg}
+}
+
+// SentinelWithID returns an immutable grr.Error like Sentinel, whose ID is given rather than read from its message.
+// Generated sentinels are declared with it, so that they print only their message like generated structs, e.g.
+//
+//	var ErrNotFound = grr.SentinelWithID("NotFound", "resource not found")
+func SentinelWithID(id string, msg string) Error {
+	return &sentinel{id: id, msg: msg}
+}
+
+// instance returns a fresh grr.Error with the sentinel's message that errors.Is matches against it
+func (e *sentinel) instance() *grrError {
+	return &grrError{msg: e.msg, traits: map[Trait]any{}, created: Now(), sentinel: e}
+}
+
+func (e *sentinel) Error() string {
+	return e.msg
+}
+
+func (e *sentinel) Unwrap() error {
+	return nil
+}
+
+func (e *sentinel) UnwrapAll() error {
+	return nil
+}
+
+func (e *sentinel) AsGrr(err Error) (Error, bool) {
+	return AsGrr(e, err)
+}
+
+func (e *sentinel) AddTrait(key Trait, value any) Error {
+	return e.instance().AddTrait(key, value)
+}
+
+func (e *sentinel) GetTrait(key Trait) (any, bool) {
+	return nil, false
+}
+
+func (e *sentinel) AddOp(op string) Error {
+	return e.instance().AddOp(op)
+}
+
+func (e *sentinel) GetOp() string {
+	return ""
+}
+
+func (e *sentinel) AddError(err error) Error {
+	return e.instance().AddError(err)
+}
+
+func (e *sentinel) GetTraits() map[Trait]any {
+	return map[Trait]any{}
+}
+
+func (e *sentinel) CreatedAt() time.Time {
+	return time.Time{}
+}
+
+func (e *sentinel) LogValue() slog.Value {
+	return LogValue(e)
+}
+
+func (e *sentinel) MarshalJSON() ([]byte, error) {
+	return MarshalJSON(e)
+}
+
+func (e *sentinel) Trace() {
+	Trace(e)
+}
+
+func (e *sentinel) Strace() string {
+	return Strace(e)
+}
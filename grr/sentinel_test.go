@@ -0,0 +1,72 @@
+package grr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSentinel(t *testing.T) {
+	errNotFound := Sentinel("NotFound: resource not found")
+	errOther := Sentinel("NotFound: resource not found")
+
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{name: "itself", err: errNotFound, target: errNotFound, want: true},
+		{name: "with trait", err: errNotFound.AddTrait(TrCode, "NotFound"), target: errNotFound, want: true},
+		{name: "with op", err: errNotFound.AddOp("pkg.Get"), target: errNotFound, want: true},
+		{name: "wrapped", err: fmt.Errorf("get: %w", errNotFound.AddError(errors.New("root"))), target: errNotFound, want: true},
+		{name: "same message", err: errNotFound.AddOp("pkg.Get"), target: errOther, want: false},
+		{name: "errorf", err: Errorf("NotFound: resource not found"), target: errNotFound, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := errors.Is(test.err, test.target); got != test.want {
+				t.Errorf("errors.Is() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSentinelImmutable(t *testing.T) {
+	errNotFound := Sentinel("NotFound: resource not found")
+
+	errNotFound.AddTrait(TrCode, "NotFound").AddOp("pkg.Get").AddError(errors.New("root"))
+
+	if _, ok := errNotFound.GetTrait(TrCode); ok || errNotFound.GetOp() != "" || errNotFound.Unwrap() != nil {
+		t.Errorf("annotating the sentinel changed it")
+	}
+}
+
+func TestSentinelWithID(t *testing.T) {
+	errTimeout := SentinelWithID("Timeout", "timed out")
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "itself", err: errTimeout},
+		{name: "annotated", err: errTimeout.AddOp("pkg.Get")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.err.Error(); got != "timed out" {
+				t.Errorf("Error() = %q, want %q", got, "timed out")
+			}
+
+			if got := ID(test.err); got != "Timeout" {
+				t.Errorf("ID() = %q, want %q", got, "Timeout")
+			}
+
+			if !errors.Is(test.err, errTimeout) {
+				t.Errorf("errors.Is() = false")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package grr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// OpenTelemetry semantic convention keys for exceptions, plus grr specific keys
+const (
+	AttrExceptionType       = "exception.type"
+	AttrExceptionMessage    = "exception.message"
+	AttrExceptionStacktrace = "exception.stacktrace"
+	AttrOp                  = "grr.op"
+	AttrID                  = "grr.id"
+	AttrTraitPrefix         = "grr.trait."
+)
+
+// Attr is a single span attribute. It mirrors attribute.KeyValue without depending on OpenTelemetry
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// SpanRecorder is implemented by tracer wrappers that want to record grr errors on a span
+type SpanRecorder interface {
+	RecordError(err error, attrs []Attr)
+}
+
+// Record records err on r along with its SpanAttributes. Nil errors are ignored
+func Record(r SpanRecorder, err error) {
+	if err == nil {
+		return
+	}
+
+	r.RecordError(err, SpanAttributes(err))
+}
+
+// SpanAttributes describes err using OpenTelemetry semantic convention keys.
+// The op is the outermost one set in the chain and the ID is that of the outermost grr.Error. Traits are collected from the whole chain,
+// with inner errors winning like they do in GetTrait, and are sorted by key.
+// Occurrence metadata is included when RecordOccurrences is on
+func SpanAttributes(err error) []Attr {
+	if err == nil {
+		return nil
+	}
+
+	attrs := []Attr{
+		{Key: AttrExceptionType, Value: fmt.Sprintf("%T", err)},
+		{Key: AttrExceptionMessage, Value: err.Error()},
+		{Key: AttrExceptionStacktrace, Value: Strace(err)},
+	}
+
+	traits := chainTraits(err)
+
+	if op := Op(err); op != "" {
+		attrs = append(attrs, Attr{Key: AttrOp, Value: op})
+	}
+
+	if id := ID(err); id != "" {
+		attrs = append(attrs, Attr{Key: AttrID, Value: id})
+	}
+
+	attrs = append(attrs, occurrenceAttrs(err)...)
+
+	keys := make([]Trait, 0, len(traits))
+	for k := range traits {
+		keys = append(keys, k)
+	}
+
+	slices.SortFunc(keys, func(a, b Trait) int {
+		return strings.Compare(string(a), string(b))
+	})
+
+	for _, k := range keys {
+		attrs = append(attrs, Attr{Key: AttrTraitPrefix + k.String(), Value: traits[k]})
+	}
+
+	return attrs
+}
+
+// chainTraits collects the traits of every grr.Error in the chain, with inner errors winning like they do in GetTrait
+func chainTraits(err error) map[Trait]any {
+	traits := map[Trait]any{}
+
+	Walk(err, func(_ int, e error) bool {
+		if grrErr, ok := e.(Error); ok {
+			for k, v := range grrErr.GetTraits() {
+				traits[k] = v
+			}
+		}
+
+		return true
+	})
+
+	return traits
+}
@@ -0,0 +1,76 @@
+package grr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fakeRecorder struct {
+	err   error
+	attrs []Attr
+}
+
+func (r *fakeRecorder) RecordError(err error, attrs []Attr) {
+	r.err = err
+	r.attrs = attrs
+}
+
+func TestRecord(t *testing.T) {
+	inner := Errorf("NotFound: user was not found").
+		AddTrait(TrCode, "NotFound").
+		AddTrait(NewTrait("Retry"), false)
+
+	err := Errorf("Lookup: failed").
+		AddOp("pkg.Lookup").
+		AddTrait(TrCode, "Internal").
+		AddTrait(NewTrait("Attempt"), 2).
+		AddError(inner)
+
+	recorder := &fakeRecorder{}
+	Record(recorder, err)
+
+	if recorder.err != err {
+		t.Fatalf("recorded %v, want %v", recorder.err, err)
+	}
+
+	want := []Attr{
+		{Key: AttrExceptionType, Value: "*grr.grrError"},
+		{Key: AttrExceptionMessage, Value: "Lookup: failed"},
+		{Key: AttrExceptionStacktrace, Value: Strace(err)},
+		{Key: AttrOp, Value: "pkg.Lookup"},
+		{Key: AttrID, Value: "Lookup"},
+		{Key: AttrTraitPrefix + "Attempt", Value: 2},
+		// the inner error wins
+		{Key: AttrTraitPrefix + "Code", Value: "NotFound"},
+		{Key: AttrTraitPrefix + "Retry", Value: false},
+	}
+
+	if !reflect.DeepEqual(recorder.attrs, want) {
+		t.Errorf("attrs = %v, want %v", recorder.attrs, want)
+	}
+}
+
+func TestRecordNil(t *testing.T) {
+	recorder := &fakeRecorder{}
+	Record(recorder, nil)
+
+	if recorder.err != nil || recorder.attrs != nil {
+		t.Errorf("recorded %v %v for a nil error", recorder.err, recorder.attrs)
+	}
+}
+
+func TestSpanAttributesNonGrr(t *testing.T) {
+	attrs := SpanAttributes(errors.New("plain"))
+
+	for _, attr := range attrs {
+		switch attr.Key {
+		case AttrOp, AttrID:
+			t.Errorf("unexpected %s on a plain error", attr.Key)
+		}
+	}
+
+	if len(attrs) != 3 {
+		t.Errorf("got %d attrs, want the 3 exception attrs", len(attrs))
+	}
+}
@@ -2,6 +2,9 @@ package grr
 
 type Trait string
 
+// TrCode is the trait holding an error's canonical code (e.g. "NotFound", "Internal")
+var TrCode = NewTrait("Code")
+
 func NewTrait(name string) Trait {
 	return Trait(name)
 }
@@ -0,0 +1,85 @@
+package grr
+
+import "reflect"
+
+// MaxWalkDepth is the deepest level Walk will descend to before giving up on a chain
+const MaxWalkDepth = 100
+
+// Walk calls fn for err and every error reachable from it, depth-first.
+// It follows grr links as well as the standard Unwrap() error and Unwrap() []error methods,
+// so non-grr wrappers (fmt.Errorf with %w, errors.Join, ...) are traversed like any other link.
+// Errors that have already been visited are skipped, which guards against cycles such as AddError(self),
+// and nothing deeper than MaxWalkDepth is visited. Returning false from fn stops the walk.
+func Walk(err error, fn func(depth int, e error) bool) {
+	if err == nil {
+		return
+	}
+
+	walk(err, 0, map[error]struct{}{}, fn)
+}
+
+// walk returns false once fn has asked to stop
+func walk(err error, depth int, seen map[error]struct{}, fn func(depth int, e error) bool) bool {
+	if err == nil || depth > MaxWalkDepth {
+		return true
+	}
+
+	// Only pointers can safely be used as map keys; value errors can't form a cycle on their own
+	if reflect.ValueOf(err).Kind() == reflect.Pointer {
+		if _, ok := seen[err]; ok {
+			return true
+		}
+
+		seen[err] = struct{}{}
+	}
+
+	if !fn(depth, err) {
+		return false
+	}
+
+	for _, child := range children(err) {
+		if !walk(child, depth+1, seen, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// children returns the errors directly wrapped by err
+func children(err error) []error {
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		return e.Unwrap()
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			return []error{inner}
+		}
+	}
+
+	return nil
+}
+
+// innermost returns the deepest error on the first branch of err's chain that satisfies match
+func innermost(err error, match func(e error) bool) error {
+	var found error
+
+	prevDepth := -1
+
+	Walk(err, func(depth int, e error) bool {
+		// Once the depth stops increasing we've moved past the end of the first branch
+		if depth <= prevDepth {
+			return false
+		}
+
+		prevDepth = depth
+
+		if match(e) {
+			found = e
+		}
+
+		return true
+	})
+
+	return found
+}
@@ -0,0 +1,97 @@
+package grr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	root := errors.New("root")
+	cyclic := Errorf("Cyclic: points at itself")
+	cyclic.AddError(cyclic)
+
+	// a chain deeper than MaxWalkDepth
+	var deep error = root
+	for i := 0; i < MaxWalkDepth+10; i++ {
+		deep = fmt.Errorf("level: %w", deep)
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantDepths []int
+	}{
+		{name: "nil", err: nil, wantDepths: nil},
+		{name: "single", err: root, wantDepths: []int{0}},
+		{name: "grr and fmt links", err: Errorf("Outer: failed").AddError(fmt.Errorf("wrapped: %w", root)), wantDepths: []int{0, 1, 2}},
+		{name: "join", err: errors.Join(Errorf("A: a").AddError(root), errors.New("b")), wantDepths: []int{0, 1, 2, 1}},
+		{name: "cycle", err: cyclic, wantDepths: []int{0}},
+		{name: "depth limit", err: deep, wantDepths: sequence(MaxWalkDepth + 1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var depths []int
+
+			Walk(test.err, func(depth int, _ error) bool {
+				depths = append(depths, depth)
+				return true
+			})
+
+			if !reflect.DeepEqual(depths, test.wantDepths) {
+				t.Errorf("depths = %v, want %v", depths, test.wantDepths)
+			}
+		})
+	}
+}
+
+func TestWalkStop(t *testing.T) {
+	err := Errorf("Outer: failed").AddError(Errorf("Inner: failed").AddError(errors.New("root")))
+	visited := 0
+
+	Walk(err, func(depth int, _ error) bool {
+		visited++
+		return depth < 1
+	})
+
+	if visited != 2 {
+		t.Errorf("visited %d errors, want 2", visited)
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	root := errors.New("root")
+	inner := Errorf("Inner: failed").AddTrait(TrCode, "NotFound").AddError(root)
+	outer := Errorf("Outer: failed").AddOp("pkg.Outer").AddTrait(TrCode, "Internal").AddError(fmt.Errorf("wrapped: %w", inner))
+
+	if got := UnwrapAll(outer); got != root {
+		t.Errorf("UnwrapAll() = %v, want %v", got, root)
+	}
+
+	if got := UnwrapAllGrr(outer); got != inner {
+		t.Errorf("UnwrapAllGrr() = %v, want %v", got, inner)
+	}
+
+	if got, _ := GetTrait(outer, TrCode); got != "NotFound" {
+		t.Errorf("GetTrait() = %v, want NotFound", got)
+	}
+
+	if got := ID(outer); got != "Outer" {
+		t.Errorf("ID() = %q, want Outer", got)
+	}
+
+	if got := Op(outer); got != "pkg.Outer" {
+		t.Errorf("Op() = %q, want pkg.Outer", got)
+	}
+}
+
+func sequence(n int) []int {
+	seq := make([]int, n)
+	for i := range seq {
+		seq[i] = i
+	}
+
+	return seq
+}
@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// UnifiedDiff returns the unified diff between a and b, or "" if they are equal
+func UnifiedDiff(aName, bName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+
+	ops := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+
+		if start == len(ops) {
+			break
+		}
+
+		// grow the hunk until there are more than 2*diffContext unchanged lines in a row
+		end := start
+
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+
+			if run == len(ops) || run-end > 2*diffContext {
+				break
+			}
+
+			end = run
+		}
+
+		from := max(start-diffContext, 0)
+		to := min(end+diffContext, len(ops))
+
+		writeHunk(&out, ops, from, to)
+
+		start = to
+	}
+
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, ops []diffOp, from, to int) {
+	// line numbers are 1-based and count the lines before the hunk
+	aStart, bStart := 1, 1
+
+	for _, op := range ops[:from] {
+		if op.kind != '+' {
+			aStart++
+		}
+
+		if op.kind != '-' {
+			bStart++
+		}
+	}
+
+	aLen, bLen := 0, 0
+
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			aLen++
+		}
+
+		if op.kind != '-' {
+			bLen++
+		}
+	}
+
+	// an empty range starts at the line before it
+	if aLen == 0 {
+		aStart--
+	}
+
+	if bLen == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+
+	for _, op := range ops[from:to] {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+
+		if !strings.HasSuffix(op.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// diffLines computes a shortest line diff of a and b with Myers' algorithm. It recurses on the middle snake of the edit
+// path rather than keeping every step of the search, so that it runs in linear space
+func diffLines(a, b []string) []diffOp {
+	ops := appendDiff(make([]diffOp, 0, len(a)+len(b)), a, b)
+
+	// removed lines come before the lines that replace them
+	for start := 0; start < len(ops); start++ {
+		end := start
+
+		for end < len(ops) && ops[end].kind != ' ' {
+			end++
+		}
+
+		slices.SortStableFunc(ops[start:end], func(x, y diffOp) int {
+			return cmp.Compare(y.kind, x.kind)
+		})
+
+		start = end
+	}
+
+	return ops
+}
+
+// appendDiff appends the ops turning a into b to ops
+func appendDiff(ops []diffOp, a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+	default:
+		// both differ at their ends, so there are at least two edits and both halves have fewer
+		x, y, u, v := middleSnake(a, b)
+
+		ops = appendDiff(ops, a[:x], b[:y])
+
+		for _, line := range a[x:u] {
+			ops = append(ops, diffOp{' ', line})
+		}
+
+		ops = appendDiff(ops, a[u:], b[v:])
+	}
+
+	for _, line := range common {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	return ops
+}
+
+// middleSnake returns the snake from (x, y) to (u, v) in the middle of a shortest edit path from a to b,
+// found by searching forward from the start and backward from the end until the searches overlap
+func middleSnake(a, b []string) (int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+
+	// the furthest x reached on each diagonal k = x - y, forward from (0, 0) and backward from (n, m) counted from the end
+	offset := maxD + 1
+	forward := make([]int, 2*maxD+3)
+	backward := make([]int, 2*maxD+3)
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			x := forward[offset+k-1] + 1
+
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			}
+
+			y := x - k
+			startX, startY := x, y
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			forward[offset+k] = x
+
+			// the backward search is one step behind, on the diagonal that mirrors k
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && x+backward[offset+kb] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			x := backward[offset+k-1] + 1
+
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			}
+
+			y := x - k
+			startX, startY := x, y
+
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+
+			backward[offset+k] = x
+
+			if kf := delta - k; !odd && kf >= -d && kf <= d && x+forward[offset+kf] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+
+	panic("utils: the searches of the diff didn't meet")
+}
+
+// splitLines splits s after every newline, keeping the newlines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
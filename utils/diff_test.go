@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(from, to int) string {
+		var out strings.Builder
+
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&out, "%d\n", i)
+		}
+
+		return out.String()
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		// the names of a and b, a/x and b/x by default
+		aName, bName string
+		want         string
+	}{
+		{name: "equal", a: "a\nb\n", b: "a\nb\n", want: ""},
+		{
+			name: "context",
+			a:    lines(1, 10),
+			b:    strings.Replace(lines(1, 10), "5\n", "five\n", 1),
+			want: "--- a/x\n+++ b/x\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "close changes share a hunk",
+			a:    lines(1, 12),
+			b:    strings.NewReplacer("3\n", "three\n", "9\n", "nine\n").Replace(lines(1, 12)),
+			want: "--- a/x\n+++ b/x\n@@ -1,12 +1,12 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n 7\n 8\n-9\n+nine\n 10\n 11\n 12\n",
+		},
+		{
+			name: "distant changes get their own hunks",
+			a:    lines(1, 20),
+			b:    "1\ntwo\n" + lines(3, 18) + "nineteen\n20\n",
+			want: "--- a/x\n+++ b/x\n@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n@@ -16,5 +16,5 @@\n 16\n 17\n 18\n-19\n+nineteen\n 20\n",
+		},
+		{
+			name:  "new file",
+			a:     "",
+			b:     "package p\n",
+			aName: "/dev/null",
+			want:  "--- /dev/null\n+++ b/x\n@@ -0,0 +1,1 @@\n+package p\n",
+		},
+		{
+			name:  "removed file",
+			a:     "package p\n\nvar x int\n",
+			b:     "",
+			bName: "/dev/null",
+			want:  "--- a/x\n+++ /dev/null\n@@ -1,3 +0,0 @@\n-package p\n-\n-var x int\n",
+		},
+		{
+			name: "no newline at end",
+			a:    "a\nb",
+			b:    "a\nc",
+			want: "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			aName, bName := test.aName, test.bName
+
+			if aName == "" {
+				aName = "a/x"
+			}
+
+			if bName == "" {
+				bName = "b/x"
+			}
+
+			if got := UnifiedDiff(aName, bName, []byte(test.a), []byte(test.b)); got != test.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// The diff of random inputs turns a into b with as few edits as the longest common subsequence allows
+func TestDiffLinesShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	randomLines := func() []string {
+		lines := make([]string, random.Intn(12))
+
+		for i := range lines {
+			lines[i] = string(rune('a' + random.Intn(4)))
+		}
+
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+
+		gotA, gotB, edits := []string{}, []string{}, 0
+
+		for _, op := range ops {
+			if op.kind != '+' {
+				gotA = append(gotA, op.line)
+			}
+
+			if op.kind != '-' {
+				gotB = append(gotB, op.line)
+			}
+
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("diffLines(%q, %q) = %v doesn't turn one into the other", a, b, ops)
+		}
+
+		if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+			t.Fatalf("diffLines(%q, %q) = %v has %d edits, want %d", a, b, ops, edits, want)
+		}
+	}
+}
+
+// Large files with few changes are diffed without a table of every pair of lines
+func TestDiffLinesLarge(t *testing.T) {
+	a := make([]string, 50000)
+
+	for i := range a {
+		a[i] = fmt.Sprintf("line %d\n", i)
+	}
+
+	b := append([]string{}, a...)
+	b[100] = "changed\n"
+	b = append(b[:40000], b[40010:]...)
+
+	edits := 0
+
+	for _, op := range diffLines(a, b) {
+		if op.kind != ' ' {
+			edits++
+		}
+	}
+
+	if edits != 12 {
+		t.Errorf("diffLines() has %d edits, want 12", edits)
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b
+func lcsLength(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	return lengths[0][0]
+}
@@ -31,3 +31,11 @@ func Merge[K comparable, V any](a, b map[K]V) map[K]V {
 	}
 	return m
 }
+
+func Keys[K comparable, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}